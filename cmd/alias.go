@@ -0,0 +1,112 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+import (
+	"fmt"
+	"github.com/halng/deto/pkg"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// aliasCmd represents the alias command
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Name versions, e.g. the version prod runs",
+	Long: `An alias is a name of an installed version, accepted wherever a version is.
+For example: deto alias set go work go1.21.13
+Moving the alias later updates every script using it: deto alias set go work go1.22.5
+	`,
+}
+
+var aliasSetCmd = &cobra.Command{
+	Use:   "set <candidate> <alias> <version>",
+	Short: "Point an alias to a version",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		candidate, name, version := args[0], args[1], args[2]
+		if err := pkg.SetAlias(candidate, name, version); err != nil {
+			fmt.Println("There was an error setting the alias.", err.Error())
+			os.Exit(1)
+		}
+
+		resolved, err := pkg.ResolveVersion(candidate, name)
+		if err != nil {
+			fmt.Println("There was an error reading the aliases.", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("%s %s -> %s\n", candidate, name, resolved)
+		if installed, err := pkg.IsInstalled(candidate, resolved); err == nil && !installed {
+			fmt.Printf("%s %s is not installed yet\n", candidate, resolved)
+		}
+	},
+}
+
+var aliasListCmd = &cobra.Command{
+	Use:   "list [candidate]",
+	Short: "List the aliases",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		candidate := ""
+		if len(args) == 1 {
+			candidate = args[0]
+		}
+		aliases, err := pkg.ListAliases(candidate)
+		if err != nil {
+			fmt.Println("There was an error reading the aliases.", err.Error())
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CANDIDATE\tALIAS\tVERSION\t")
+		for _, alias := range aliases {
+			status := ""
+			if installed, err := pkg.IsInstalled(alias.Candidate, alias.Version); err == nil && !installed {
+				status = "not installed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", alias.Candidate, alias.Name, alias.Version, status)
+		}
+		w.Flush()
+	},
+}
+
+var aliasRemoveCmd = &cobra.Command{
+	Use:   "remove <candidate> <alias>",
+	Short: "Remove an alias, the version stays installed",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pkg.RemoveAlias(args[0], args[1]); err != nil {
+			fmt.Println("There was an error removing the alias.", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Removed alias %s of %s\n", args[1], args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasSetCmd)
+	aliasCmd.AddCommand(aliasListCmd)
+	aliasCmd.AddCommand(aliasRemoveCmd)
+}
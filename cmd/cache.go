@@ -0,0 +1,94 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+import (
+	"fmt"
+	"github.com/halng/deto/pkg"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the download cache",
+	Long: `Downloaded archives are kept in a cache keyed by their checksum, so installing the same version again
+never downloads it twice. The least recently used archives are evicted when the cache grows above its size cap.
+The cache is ~/.devtools/cache, or cache.dir of the config (DETO_CACHE_DIR), and is shared by every install root.
+For example: deto cache list
+	`,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the cached archives",
+	Run: func(cmd *cobra.Command, args []string) {
+		archives, err := pkg.ListCachedArchives()
+		if err != nil {
+			fmt.Println("There was an error reading the cache.", err.Error())
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tLAST USED\tKEY")
+		for _, archive := range archives {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", archive.Name, pkg.FormatBytes(archive.Size), archive.LastUsed.Format("2006-01-02 15:04"), archive.Key)
+		}
+		w.Flush()
+	},
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove every cached archive and partial download",
+	Run: func(cmd *cobra.Command, args []string) {
+		freed, err := pkg.CleanArchiveCache()
+		if err != nil {
+			fmt.Println("There was an error cleaning the cache.", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s from the cache\n", pkg.FormatBytes(freed))
+	},
+}
+
+var cacheSizeCmd = &cobra.Command{
+	Use:   "size",
+	Short: "Print the size of the cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		size, err := pkg.ArchiveCacheSize()
+		if err != nil {
+			fmt.Println("There was an error reading the cache.", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("%s (cap %s)\n", pkg.FormatBytes(size), pkg.FormatBytes(pkg.DefaultCacheMaxSize))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheCleanCmd)
+	cacheCmd.AddCommand(cacheSizeCmd)
+}
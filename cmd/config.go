@@ -0,0 +1,238 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"github.com/halng/deto/configs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and change the settings of deto",
+	Long: `Settings are read from ~/.deto (TOML) or the file given with --config. Every key can be overridden by an
+environment variable named after it, e.g. DETO_INSTALL_AUTO_DEFAULT for install.auto_default.
+For example: deto config set install.auto_default always
+	`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the effective settings",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys := viper.AllKeys()
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s = %v\n", key, maskConfigValue(key, viper.Get(key)))
+		}
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !viper.IsSet(args[0]) {
+			fmt.Printf("%s is not set\n", args[0])
+			os.Exit(1)
+		}
+		fmt.Println(maskConfigValue(strings.ToLower(args[0]), viper.Get(args[0])))
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in the config file",
+	Long: `Change a setting in the config file. Lists are comma separated, e.g.
+deto config set registry.sources https://registry.corp/%s.json,https://raw.githubusercontent.com/halng/deto/refs/heads/main/registry/%s_versions.json
+Tables such as mirrors or network.hosts are changed with deto config edit.
+	`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := strings.ToLower(args[0])
+		value, err := parseConfigValue(key, args[1])
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		path, err := configFilePath()
+		if err != nil {
+			fmt.Println("There was an error finding the config file.", err.Error())
+			os.Exit(1)
+		}
+		fileConfig, err := readConfigFile(path)
+		if err != nil {
+			fmt.Println("There was an error reading the config file.", err.Error())
+			os.Exit(1)
+		}
+
+		// check the whole config with the new value before writing it
+		viper.Set(key, value)
+		if _, err := configs.Load(); err != nil {
+			fmt.Println("The value is not valid.", err.Error())
+			os.Exit(1)
+		}
+
+		fileConfig.Set(key, value)
+		if err := fileConfig.WriteConfigAs(path); err != nil {
+			fmt.Println("There was an error writing the config file.", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("%s = %v\n", key, value)
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $VISUAL or $EDITOR",
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := configFilePath()
+		if err != nil {
+			fmt.Println("There was an error finding the config file.", err.Error())
+			os.Exit(1)
+		}
+		// the file may hold credentials
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Println("There was an error creating the config file.", err.Error())
+			os.Exit(1)
+		}
+		file.Close()
+
+		editor := strings.Fields(os.Getenv("VISUAL"))
+		if len(editor) == 0 {
+			editor = strings.Fields(os.Getenv("EDITOR"))
+		}
+		if len(editor) == 0 {
+			editor = []string{"vi"}
+			if runtime.GOOS == "windows" {
+				editor = []string{"notepad"}
+			}
+		}
+
+		editCmd := exec.Command(editor[0], append(editor[1:], path)...)
+		editCmd.Stdin, editCmd.Stdout, editCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := editCmd.Run(); err != nil {
+			fmt.Println("There was an error running the editor.", err.Error())
+			os.Exit(1)
+		}
+
+		viper.SetConfigFile(path)
+		if err := viper.ReadInConfig(); err != nil {
+			fmt.Println("The config file is not valid.", err.Error())
+			os.Exit(1)
+		}
+		if _, err := configs.Load(); err != nil {
+			fmt.Println("The config is not valid.", err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// sensitiveConfigKeys hold credentials, their values are never printed
+var sensitiveConfigKeys = []string{"network.headers", "network.hosts"}
+
+// maskConfigValue hides the credentials of a value: the sensitive keys, the sensitive keys of a table such as
+// network, and the passwords of proxy URLs
+func maskConfigValue(key string, value any) any {
+	for _, sensitive := range sensitiveConfigKeys {
+		if key == sensitive || strings.HasPrefix(key, sensitive+".") {
+			return "(hidden)"
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(value))
+		for subKey, subValue := range value {
+			masked[subKey] = maskConfigValue(strings.TrimPrefix(key+"."+strings.ToLower(subKey), "."), subValue)
+		}
+		return masked
+	case string:
+		if parsed, err := url.Parse(value); err == nil && parsed.User != nil {
+			return parsed.Redacted()
+		}
+	}
+	return value
+}
+
+// readConfigFile reads the config file alone, without the defaults and env variables
+func readConfigFile(path string) (*viper.Viper, error) {
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(path)
+	if ext := filepath.Ext(path); ext == "" || ext == filepath.Base(path) {
+		fileConfig.SetConfigType("toml")
+	}
+	fileConfig.SetConfigPermissions(0600)
+	if err := fileConfig.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return fileConfig, nil
+}
+
+// parseConfigValue converts value to the type of the default of key
+func parseConfigValue(key string, value string) (any, error) {
+	defaultValue, ok := configs.Defaults[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s, tables such as mirrors or network.hosts are changed with deto config edit", key)
+	}
+
+	switch defaultValue.(type) {
+	case bool:
+		return strconv.ParseBool(value)
+	case int:
+		return strconv.Atoi(value)
+	case []string:
+		values := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values, nil
+	default:
+		return value, nil
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+}
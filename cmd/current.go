@@ -0,0 +1,78 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+import (
+	"fmt"
+	"github.com/halng/deto/pkg"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// currentCmd represents the current command
+var currentCmd = &cobra.Command{
+	Use:   "current [candidate]",
+	Short: "Print the default versions",
+	Long: `Print the default version of a candidate, or the default version and directory of every candidate.
+It exits with 1 when the candidate has no default version.`,
+	Example: `  deto current
+  deto current java`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configData, err := pkg.LoadData()
+		if err != nil {
+			fmt.Println("There was an error reading the installed versions.", err.Error())
+			os.Exit(1)
+		}
+
+		if len(args) == 1 {
+			for _, config := range configData {
+				if config.Candidate == args[0] && config.Current != "" {
+					fmt.Println(config.Current)
+					return
+				}
+			}
+			fmt.Printf("%s has no default version\n", args[0])
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CANDIDATE\tVERSION\tDIRECTORY")
+		for _, config := range configData {
+			if config.Current == "" {
+				continue
+			}
+			dir, err := pkg.VersionDir(config.Candidate, config.Current)
+			if err != nil {
+				fmt.Println("There was an error finding the version directory.", err.Error())
+				os.Exit(1)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", config.Candidate, config.Current, dir)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(currentCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+import (
+	"github.com/spf13/cobra"
+)
+
+// defaultCmd represents the default command
+var defaultCmd = &cobra.Command{
+	Use:   "default <candidate> <version>",
+	Short: "Make an installed version the default one",
+	Long: `Make an installed version, or a version of a shared root, the default one of a candidate.
+The version may be an alias. The post_default hooks run afterwards.`,
+	Example: `  deto default java 21.0.4-tem
+  deto default go work`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		man := newMan(cmd, "default", args[0], args[1])
+		man.Handler()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(defaultCmd)
+}
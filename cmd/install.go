@@ -0,0 +1,61 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+import (
+	"github.com/halng/deto/configs"
+	"github.com/halng/deto/pkg"
+	"github.com/spf13/cobra"
+)
+
+// installCmd represents the install command
+var installCmd = &cobra.Command{
+	Use:   "install <candidate> [version]",
+	Short: "Install a version of a candidate",
+	Long: `Install a version of a candidate. The version is the one shown by deto man, e.g. 21.0.4-tem, a plain version
+such as 21 for its latest build, or an alias. Without a version, the available versions are listed to pick one.
+Whether the new version becomes the default one follows install.auto_default of the config, or --default.
+The post_install hooks run afterwards.`,
+	Example: `  deto install java 21.0.4-tem
+  deto install java 21 --vendor amzn --image jre
+  deto install go go1.23.2 --default`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		version := ""
+		if len(args) == 2 {
+			version = args[1]
+		}
+		makeDefault, _ := cmd.Flags().GetBool("default")
+		if makeDefault {
+			pkg.AutoDefault = configs.AutoDefaultAlways
+		}
+
+		man := newMan(cmd, "install", args[0], version)
+		man.Handler()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(installCmd)
+	addInstallFlags(installCmd)
+	installCmd.Flags().Bool("default", false, "Make the new version the default one")
+}
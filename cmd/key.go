@@ -0,0 +1,96 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+import (
+	"fmt"
+	"github.com/halng/deto/pkg"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// keyCmd represents the key command
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the keys trusted to sign artifacts",
+	Long: `Registry entries can reference a detached OpenPGP or minisign signature and the fingerprint of the key that signs them.
+Deto verifies the signature against the trusted keys and refuses the install when it fails.
+For example, to trust the Adoptium key: deto key import adoptium.asc
+	`,
+}
+
+var keyImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Trust the OpenPGP or minisign public keys of a file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := pkg.ImportKey(args[0])
+		if err != nil {
+			fmt.Println("There was an error importing the key.", err.Error())
+			os.Exit(1)
+		}
+		for _, key := range keys {
+			fmt.Printf("Imported %s key %s %s\n", key.Type, key.Fingerprint, key.Identity)
+		}
+	},
+}
+
+var keyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the trusted keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := pkg.ListKeys()
+		if err != nil {
+			fmt.Println("There was an error reading the keys.", err.Error())
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FINGERPRINT\tTYPE\tIDENTITY")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", key.Fingerprint, key.Type, key.Identity)
+		}
+		w.Flush()
+	},
+}
+
+var keyRemoveCmd = &cobra.Command{
+	Use:   "remove <fingerprint>",
+	Short: "Stop trusting a key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pkg.RemoveKey(args[0]); err != nil {
+			fmt.Println("There was an error removing the key.", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Removed key %s\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keyCmd)
+	keyCmd.AddCommand(keyImportCmd)
+	keyCmd.AddCommand(keyListCmd)
+	keyCmd.AddCommand(keyRemoveCmd)
+}
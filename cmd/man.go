@@ -28,7 +28,6 @@ import (
 	"github.com/halng/deto/tui"
 	"github.com/spf13/cobra"
 	"os"
-	"runtime"
 )
 
 // ManCmd represents the man command
@@ -41,6 +40,7 @@ Available actions: install, remove, list, default
 Let's combine them to use deto. 
 For example: deto man
 There will be a prompt to ask you to choose the candidate and action type. You just need to follow the instructions.
+Scripts should use the install, remove, list, default and current commands instead, e.g. deto install java 21.0.4-tem
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		tui.Clear()
@@ -74,22 +74,64 @@ There will be a prompt to ask you to choose the candidate and action type. You j
 
 		tui.Clear()
 
-		os := runtime.GOOS
-		arch := runtime.GOARCH
-
-		var man = pkg.Man{
-			Candidate:       candidate,
-			ActionType:      actionType,
-			OperatingSystem: os,
-			Architecture:    arch,
-		}
-
+		man := newMan(cmd, actionType, candidate, "")
 		man.Handler()
 	},
 }
 
+// addInstallFlags registers the flags that pick and download the version to install
+func addInstallFlags(cmd *cobra.Command) {
+	cmd.Flags().String("vendor", "", "Vendor of the candidate, e.g. tem, amzn, zulu, librca, graal for java, instead of the vendor prompt")
+	cmd.Flags().String("image", "", "Image type of the candidate, e.g. jdk, jre, debugimage, jdk-fx, jre-fx for java, jdk by default")
+	cmd.Flags().Int("parallel", 0, "Number of connections used to download large archives, 1 disables parallel downloads")
+	cmd.Flags().Bool("stream", false, "Extract tarballs while they download, without keeping the archive in the cache")
+}
+
+// newMan returns the Man of an action, with the install flags of cmd when it has them
+func newMan(cmd *cobra.Command, actionType string, candidate string, version string) pkg.Man {
+	man := pkg.Man{
+		Candidate:       candidate,
+		ActionType:      actionType,
+		OperatingSystem: appConfig.Platform.OS,
+		Architecture:    appConfig.Platform.Arch,
+		Version:         version,
+		Stream:          appConfig.Install.Stream,
+	}
+	if cmd.Flags().Lookup("vendor") == nil {
+		return man
+	}
+
+	var err error
+	if man.Vendor, err = cmd.Flags().GetString("vendor"); err != nil {
+		fmt.Println("There was an error getting the vendor.", err.Error())
+		os.Exit(1)
+	}
+	if man.ImageType, err = cmd.Flags().GetString("image"); err != nil {
+		fmt.Println("There was an error getting the image type.", err.Error())
+		os.Exit(1)
+	}
+
+	parallel, err := cmd.Flags().GetInt("parallel")
+	if err != nil {
+		fmt.Println("There was an error getting the parallelism.", err.Error())
+		os.Exit(1)
+	}
+	if parallel > 0 {
+		pkg.DefaultParallelism = parallel
+	}
+
+	if cmd.Flags().Changed("stream") {
+		if man.Stream, err = cmd.Flags().GetBool("stream"); err != nil {
+			fmt.Println("There was an error getting the stream flag.", err.Error())
+			os.Exit(1)
+		}
+	}
+	return man
+}
+
 func init() {
 	rootCmd.AddCommand(manCmd)
 	manCmd.Flags().StringP("action", "a", "", "Action name. [install|remove|list|default]")
 	manCmd.Flags().StringP("candidate", "c", "", "Candidate name")
+	addInstallFlags(manCmd)
 }
@@ -23,8 +23,14 @@ THE SOFTWARE.
 */
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"github.com/halng/deto/configs"
+	"github.com/halng/deto/pkg"
+	"github.com/halng/deto/tui"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -32,19 +38,20 @@ import (
 
 var cfgFile string
 
+// appConfig is the loaded config file with its env overrides
+var appConfig configs.Config
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "deto",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	// Run: func(cmd *cobra.Command, args []string) { },
+	Short: "A version manager for developer tools",
+	Long: `Deto installs, removes and switches the versions of developer tools such as java and go.
+Versions are installed in ~/.devtools (or DETO_HOME), the default version of a candidate is <candidate>/current.
+For example:
+  deto install java 21.0.4-tem
+  deto default java 21.0.4-tem
+  deto current
+Run deto man for an interactive wizard.`,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -63,11 +70,9 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.deto.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file in TOML (default is $HOME/.deto)")
+	rootCmd.PersistentFlags().String("home", "", "install root, also set with DETO_HOME (default is $HOME/.devtools)")
+	cobra.CheckErr(viper.BindPFlag("home", rootCmd.PersistentFlags().Lookup("home")))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -86,10 +91,41 @@ func initConfig() {
 		viper.SetConfigName(".deto")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	// defaults and DETO_* environment variables
+	configs.Setup()
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	} else if !errors.As(err, &viper.ConfigFileNotFoundError{}) && !errors.Is(err, os.ErrNotExist) {
+		fmt.Println("There was an error reading the config file.", err.Error())
+		os.Exit(1)
+	}
+
+	var err error
+	appConfig, err = configs.Load()
+	if err != nil {
+		fmt.Println("The config is not valid.", err.Error())
+		os.Exit(1)
+	}
+	if err := pkg.ApplyConfig(appConfig); err != nil {
+		fmt.Println("The config is not valid.", err.Error())
+		os.Exit(1)
+	}
+	tui.Plain = tui.Plain || appConfig.Output.Mode == configs.OutputPlain
+}
+
+// configFilePath returns the config file that config set and config edit write to
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(home, ".deto"), nil
 }
@@ -0,0 +1,108 @@
+package cmd
+
+/*
+Copyright © 2024 Hal Ng <haonguyentan2001@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+import (
+	"fmt"
+	"github.com/halng/deto/pkg"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [candidate] [version]",
+	Short: "Check installed versions against their manifest",
+	Long: `Every install records the size, mode and SHA-256 of its files. Verify hashes the installed tree again and reports
+the files that were modified, removed or added since the install.
+Without arguments every installed version is checked, with a candidate every version of the candidate.
+For example: deto verify java 21.0.9-tem
+	`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		type install struct{ candidate, version string }
+		configs, err := pkg.LoadData()
+		if err != nil {
+			fmt.Println("There was an error reading the installed versions.", err.Error())
+			os.Exit(1)
+		}
+
+		// the version may be an alias
+		if len(args) > 1 {
+			if args[1], err = pkg.ResolveVersion(args[0], args[1]); err != nil {
+				fmt.Println("There was an error reading the aliases.", err.Error())
+				os.Exit(1)
+			}
+		}
+
+		var installs []install
+		for _, config := range configs {
+			if len(args) > 0 && config.Candidate != args[0] {
+				continue
+			}
+			for _, version := range config.Versions() {
+				if len(args) > 1 && version != args[1] {
+					continue
+				}
+				installs = append(installs, install{config.Candidate, version})
+			}
+		}
+		if len(installs) == 0 {
+			fmt.Println("No installed version matches")
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, inst := range installs {
+			report, err := pkg.VerifyInstall(inst.candidate, inst.version)
+			if err != nil {
+				fmt.Printf("%s %s: %s\n", inst.candidate, inst.version, err)
+				failed = true
+				continue
+			}
+			if report.OK() {
+				fmt.Printf("%s %s: OK\n", inst.candidate, inst.version)
+				continue
+			}
+
+			failed = true
+			fmt.Printf("%s %s: %d modified, %d missing, %d extra\n", inst.candidate, inst.version, len(report.Modified), len(report.Missing), len(report.Extra))
+			for _, change := range report.Modified {
+				fmt.Printf("  modified: %s (%s)\n", change.Path, change.Reason)
+			}
+			for _, path := range report.Missing {
+				fmt.Printf("  missing:  %s\n", path)
+			}
+			for _, path := range report.Extra {
+				fmt.Printf("  extra:    %s\n", path)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
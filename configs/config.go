@@ -1,4 +1,211 @@
 package configs
 
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config is the config file, ~/.deto in TOML by default, for example:
+//
+//	[install]
+//	auto_default = "always"
+//	parallelism = 8
+//
+//	[cache]
+//	max_size = "10GiB"
+//
+// Every key with a default can be overridden by an env variable named after it, e.g. DETO_CACHE_MAX_SIZE for
+// cache.max_size.
 type Config struct {
+	// Home is the install root, ~/.devtools when empty
+	Home string `mapstructure:"home"`
+	// SharedRoots are read-only install roots whose versions are available too, e.g. /opt/deto
+	SharedRoots []string       `mapstructure:"shared_roots"`
+	Registry    RegistryConfig `mapstructure:"registry"`
+	Platform    PlatformConfig `mapstructure:"platform"`
+	Install     InstallConfig  `mapstructure:"install"`
+	Cache       CacheConfig    `mapstructure:"cache"`
+	Network     NetworkConfig  `mapstructure:"network"`
+	Mirrors     []MirrorConfig `mapstructure:"mirrors"`
+	Output      OutputConfig   `mapstructure:"output"`
+	Hooks       HooksConfig    `mapstructure:"hooks"`
+}
+
+type RegistryConfig struct {
+	// Sources are the registry URLs tried in order, %s is replaced by the candidate
+	Sources []string `mapstructure:"sources"`
+}
+
+// PlatformConfig picks the builds to install, it defaults to the running platform
+type PlatformConfig struct {
+	OS   string `mapstructure:"os"`
+	Arch string `mapstructure:"arch"`
+}
+
+type InstallConfig struct {
+	// AutoDefault tells whether a new version becomes the default one: ask, always, never, or first for the
+	// first version of a candidate only
+	AutoDefault       string `mapstructure:"auto_default"`
+	Stream            bool   `mapstructure:"stream"`
+	Parallelism       int    `mapstructure:"parallelism"`
+	RequireSignatures bool   `mapstructure:"require_signatures"`
+	// the limits of an extraction, 0 disables a limit
+	MaxTotalSize string `mapstructure:"max_total_size"`
+	MaxFiles     int    `mapstructure:"max_files"`
+	MaxFileSize  string `mapstructure:"max_file_size"`
+	MaxPathDepth int    `mapstructure:"max_path_depth"`
+}
+
+type CacheConfig struct {
+	// Dir is the cache root shared by every install root, ~/.devtools/cache when empty
+	Dir string `mapstructure:"dir"`
+	// MaxSize is the size cap of the archive cache, e.g. 5GiB
+	MaxSize string `mapstructure:"max_size"`
+}
+
+// HooksConfig are shell commands run with DETO_CANDIDATE, DETO_VERSION and DETO_HOME_DIR set, e.g.
+//
+//	[hooks]
+//	post_install = ["$DETO_HOME_DIR/bin/keytool -importcert -noprompt -cacerts -storepass changeit -file /etc/corp-ca.pem"]
+type HooksConfig struct {
+	PostInstall []string `mapstructure:"post_install"`
+	PostDefault []string `mapstructure:"post_default"`
+	PreRemove   []string `mapstructure:"pre_remove"`
+	// AllowRegistry runs the hooks of registry entries too
+	AllowRegistry bool   `mapstructure:"allow_registry"`
+	Timeout       string `mapstructure:"timeout"`
+}
+
+type OutputConfig struct {
+	// Mode is interactive, or plain to never clear the screen nor open interactive tables, e.g. in CI
+	Mode string `mapstructure:"mode"`
+}
+
+const (
+	AutoDefaultAsk    = "ask"
+	AutoDefaultAlways = "always"
+	AutoDefaultNever  = "never"
+	AutoDefaultFirst  = "first"
+
+	OutputInteractive = "interactive"
+	OutputPlain       = "plain"
+)
+
+// Defaults are the keys that can be set with config set and overridden by env variables
+var Defaults = map[string]any{
+	"home":                       "",
+	"shared_roots":               []string{},
+	"registry.sources":           []string{"https://raw.githubusercontent.com/halng/deto/refs/heads/main/registry/%s_versions.json"},
+	"platform.os":                runtime.GOOS,
+	"platform.arch":              runtime.GOARCH,
+	"install.auto_default":       AutoDefaultAsk,
+	"install.stream":             false,
+	"install.parallelism":        4,
+	"install.require_signatures": false,
+	"install.max_total_size":     "16GiB",
+	"install.max_files":          200_000,
+	"install.max_file_size":      "4GiB",
+	"install.max_path_depth":     64,
+	"cache.dir":                  "",
+	"cache.max_size":             "5GiB",
+	"network.http_proxy":         "",
+	"network.https_proxy":        "",
+	"network.no_proxy":           "",
+	"network.ca_bundles":         []string{},
+	"network.netrc":              "",
+	"output.mode":                OutputInteractive,
+	"hooks.post_install":         []string{},
+	"hooks.post_default":         []string{},
+	"hooks.pre_remove":           []string{},
+	"hooks.allow_registry":       false,
+	"hooks.timeout":              "10m",
+}
+
+// Setup registers the defaults and the DETO_* env variables in viper
+func Setup() {
+	for key, value := range Defaults {
+		viper.SetDefault(key, value)
+	}
+	viper.SetEnvPrefix("DETO")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+}
+
+// Load reads the config from viper and validates it
+func Load() (Config, error) {
+	var config Config
+	if err := viper.Unmarshal(&config); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if !slices.Contains([]string{AutoDefaultAsk, AutoDefaultAlways, AutoDefaultNever, AutoDefaultFirst}, c.Install.AutoDefault) {
+		errs = append(errs, fmt.Errorf("install.auto_default must be ask, always, never or first, not %q", c.Install.AutoDefault))
+	}
+	if !slices.Contains([]string{OutputInteractive, OutputPlain}, c.Output.Mode) {
+		errs = append(errs, fmt.Errorf("output.mode must be interactive or plain, not %q", c.Output.Mode))
+	}
+	if len(c.Registry.Sources) == 0 {
+		errs = append(errs, errors.New("registry.sources can't be empty"))
+	}
+	if _, err := time.ParseDuration(c.Hooks.Timeout); err != nil {
+		errs = append(errs, fmt.Errorf("hooks.timeout: %w", err))
+	}
+	for key, value := range map[string]string{
+		"install.max_total_size": c.Install.MaxTotalSize,
+		"install.max_file_size":  c.Install.MaxFileSize,
+		"cache.max_size":         c.Cache.MaxSize,
+	} {
+		if _, err := ParseSize(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1000,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1000 * 1000,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1000 * 1000 * 1000,
+	"GIB": 1 << 30,
+	"T":   1 << 40,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"TIB": 1 << 40,
+}
+
+// ParseSize parses sizes such as 512MiB, 5GB or 1024
+func ParseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	idx := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	number, unit := value, ""
+	if idx >= 0 {
+		number, unit = value[:idx], strings.ToUpper(strings.TrimSpace(value[idx:]))
+	}
+
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", value)
+	}
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(size * float64(multiplier)), nil
 }
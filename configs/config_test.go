@@ -0,0 +1,100 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"512B", 512, false},
+		{"5GiB", 5 << 30, false},
+		{"5GB", 5_000_000_000, false},
+		{"5gib", 5 << 30, false},
+		{"10 MiB", 10 << 20, false},
+		{"1.5K", 1536, false},
+		{" 2T ", 2 << 40, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"GiB", 0, true},
+		{"5PB", 0, true},
+		{"-1GiB", 0, true},
+		{"1.2.3MB", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.value)
+		if test.wantErr != (err != nil) || got != test.want {
+			t.Errorf("%q: got %d, %v, want %d and an error: %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	t.Setenv("DETO_CACHE_MAX_SIZE", "1GiB")
+	t.Setenv("DETO_INSTALL_PARALLELISM", "8")
+	Setup()
+
+	config, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Cache.MaxSize != "1GiB" || config.Install.Parallelism != 8 {
+		t.Fatalf("got cache.max_size %q and install.parallelism %d, want the env variables", config.Cache.MaxSize, config.Install.Parallelism)
+	}
+	if config.Install.AutoDefault != AutoDefaultAsk || config.Output.Mode != OutputInteractive || config.Hooks.Timeout != "10m" {
+		t.Fatalf("got %+v, want the defaults", config)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	Setup()
+	defaults, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr []string
+	}{
+		{"defaults", func(c *Config) {}, nil},
+		{"auto_default", func(c *Config) { c.Install.AutoDefault = "sometimes" }, []string{"install.auto_default"}},
+		{"output mode", func(c *Config) { c.Output.Mode = "fancy" }, []string{"output.mode"}},
+		{"no registry", func(c *Config) { c.Registry.Sources = nil }, []string{"registry.sources"}},
+		{"hook timeout", func(c *Config) { c.Hooks.Timeout = "10" }, []string{"hooks.timeout"}},
+		{"sizes", func(c *Config) {
+			c.Install.MaxTotalSize, c.Install.MaxFileSize, c.Cache.MaxSize = "big", "4XB", "-1"
+		}, []string{"install.max_total_size", "install.max_file_size", "cache.max_size"}},
+		{"every error is reported", func(c *Config) {
+			c.Install.AutoDefault, c.Output.Mode = "", ""
+		}, []string{"install.auto_default", "output.mode"}},
+	}
+	for _, test := range tests {
+		config := defaults
+		config.Registry.Sources = append([]string(nil), defaults.Registry.Sources...)
+		test.change(&config)
+
+		err := config.Validate()
+		if test.wantErr == nil && err != nil {
+			t.Errorf("%s: got %v", test.name, err)
+			continue
+		}
+		for _, key := range test.wantErr {
+			if err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("%s: got %v, want an error about %s", test.name, err, key)
+			}
+		}
+	}
+}
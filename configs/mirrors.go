@@ -0,0 +1,23 @@
+package configs
+
+import "github.com/spf13/viper"
+
+// MirrorConfig is a [[mirrors]] entry of the config file. URLs starting with Prefix are rewritten to every
+// mirror in order, for example:
+//
+//	[[mirrors]]
+//	prefix = "https://go.dev/dl/"
+//	urls = ["https://artifacts.corp/go/"]
+type MirrorConfig struct {
+	Prefix string   `mapstructure:"prefix"`
+	URLs   []string `mapstructure:"urls"`
+	// SkipOrigin doesn't fall back to the original URL, for hosts that can't be reached at all
+	SkipOrigin bool `mapstructure:"skip_origin"`
+}
+
+// LoadMirrors reads the mirror rules from the loaded config file
+func LoadMirrors() ([]MirrorConfig, error) {
+	var mirrors []MirrorConfig
+	err := viper.UnmarshalKey("mirrors", &mirrors)
+	return mirrors, err
+}
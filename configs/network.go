@@ -0,0 +1,45 @@
+package configs
+
+import "github.com/spf13/viper"
+
+// NetworkConfig is the [network] table of the config file, for example:
+//
+//	[network]
+//	https_proxy = "socks5://proxy.corp:1080"
+//	no_proxy = "localhost,.corp"
+//	ca_bundles = ["/etc/ssl/corp-root.pem"]
+//	headers = { "X-Team" = "platform" }
+//
+//	[[network.hosts]]
+//	host = "artifacts.corp"
+//	token = "..."
+type NetworkConfig struct {
+	// HTTPProxy, HTTPSProxy and NoProxy override the HTTP_PROXY, HTTPS_PROXY and NO_PROXY env variables.
+	// Proxies can be http://, https:// or socks5:// URLs.
+	HTTPProxy  string `mapstructure:"http_proxy"`
+	HTTPSProxy string `mapstructure:"https_proxy"`
+	NoProxy    string `mapstructure:"no_proxy"`
+	// CABundles are PEM files trusted on top of the system roots, e.g. for TLS intercepting proxies
+	CABundles []string `mapstructure:"ca_bundles"`
+	// Netrc is the netrc file used for credentials, default is ~/.netrc. Set it to "off" to disable it.
+	Netrc string `mapstructure:"netrc"`
+	// Headers are sent with every request
+	Headers map[string]string `mapstructure:"headers"`
+	Hosts   []HostConfig      `mapstructure:"hosts"`
+}
+
+// HostConfig holds the credentials and headers sent to a single host
+type HostConfig struct {
+	Host     string            `mapstructure:"host"`
+	Username string            `mapstructure:"username"`
+	Password string            `mapstructure:"password"`
+	Token    string            `mapstructure:"token"`
+	Headers  map[string]string `mapstructure:"headers"`
+}
+
+// LoadNetwork reads the network settings from the loaded config file
+func LoadNetwork() (NetworkConfig, error) {
+	var network NetworkConfig
+	err := viper.UnmarshalKey("network", &network)
+	return network, err
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// == In this file, we manage the aliases of versions. == //
+// An alias is a name of a version of a candidate, e.g. work for go1.21.13. Aliases are kept in deto.json and
+// accepted wherever a version is, so that moving an alias to another version updates every script using it.
+
+// Alias is a name of a version
+type Alias struct {
+	Candidate string
+	Name      string
+	Version   string
+}
+
+func validateAliasName(name string) error {
+	if name == "" || name == "current" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%q can't be an alias", name)
+	}
+	return nil
+}
+
+// SetAlias points an alias of the candidate to a version. The version may be an alias too, it is resolved.
+func SetAlias(candidate string, name string, version string) error {
+	if err := validateAliasName(name); err != nil {
+		return err
+	}
+	return updateData(func(configs []Config) ([]Config, error) {
+		idx := slices.IndexFunc(configs, func(config Config) bool { return config.Candidate == candidate })
+		if idx < 0 {
+			configs = append(configs, Config{Candidate: candidate, Installations: []Installation{}})
+			idx = len(configs) - 1
+		}
+		config := &configs[idx]
+
+		if _, ok := config.Find(name); ok {
+			return nil, fmt.Errorf("%s %s is an installed version, it can't be an alias", candidate, name)
+		}
+		if target, ok := config.Aliases[version]; ok {
+			version = target
+		}
+		if config.Aliases == nil {
+			config.Aliases = map[string]string{}
+		}
+		config.Aliases[name] = version
+		return configs, nil
+	})
+}
+
+// RemoveAlias removes an alias of the candidate
+func RemoveAlias(candidate string, name string) error {
+	return updateData(func(configs []Config) ([]Config, error) {
+		for i, config := range configs {
+			if _, ok := config.Aliases[name]; ok && config.Candidate == candidate {
+				delete(configs[i].Aliases, name)
+				return configs, nil
+			}
+		}
+		return nil, fmt.Errorf("%s has no alias %s", candidate, name)
+	})
+}
+
+// ListAliases returns the aliases of a candidate, or of every candidate when it is empty
+func ListAliases(candidate string) ([]Alias, error) {
+	configs, err := LoadData()
+	if err != nil {
+		return nil, err
+	}
+	var aliases []Alias
+	for _, config := range configs {
+		if candidate != "" && config.Candidate != candidate {
+			continue
+		}
+		for name, version := range config.Aliases {
+			aliases = append(aliases, Alias{Candidate: config.Candidate, Name: name, Version: version})
+		}
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if aliases[i].Candidate != aliases[j].Candidate {
+			return aliases[i].Candidate < aliases[j].Candidate
+		}
+		return aliases[i].Name < aliases[j].Name
+	})
+	return aliases, nil
+}
+
+// ResolveVersion returns the version an alias points to, or the version itself when it isn't an alias
+func ResolveVersion(candidate string, version string) (string, error) {
+	configs, err := LoadData()
+	if err != nil {
+		return "", err
+	}
+	for _, config := range configs {
+		if target, ok := config.Aliases[version]; ok && config.Candidate == candidate {
+			return target, nil
+		}
+	}
+	return version, nil
+}
+
+// IsInstalled tells whether a version of the candidate is installed, or available in a shared root
+func IsInstalled(candidate string, version string) (bool, error) {
+	_, ok, err := findInstallation(candidate, version)
+	return ok, err
+}
@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRemoveVersionDropsItsAliases(t *testing.T) {
+	DetoHome = t.TempDir()
+	defer func() { DetoHome = "" }()
+
+	installTestVersion(t, "go", "1.0", "one")
+	installTestVersion(t, "go", "2.0", "two")
+	for name, version := range map[string]string{"work": "1.0", "old": "1.0", "stable": "2.0"} {
+		if err := SetAlias("go", name, version); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	man := &Man{Candidate: "go"}
+	man.removeVersion("work")
+
+	aliases, err := ListAliases("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Alias{{Candidate: "go", Name: "stable", Version: "2.0"}}; !slices.Equal(aliases, want) {
+		t.Fatalf("got aliases %v, want %v", aliases, want)
+	}
+
+	// the last version goes with the last alias, nothing of go is left
+	man.removeVersion("2.0")
+	configs, err := LoadData()
+	if err != nil || len(configs) != 0 {
+		t.Fatalf("got %v, %v, want no candidate left", configs, err)
+	}
+}
@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// == In this file, we manage the content-addressed archive cache. == //
+// Archives are stored as <cache>/archives/<algo>-<checksum>/<name>, so the same archive is shared by every install
+// and every install root, and never downloaded twice. The modification time of an entry is its last use and drives the LRU eviction.
+
+type CachedArchive struct {
+	Key      string
+	Name     string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+func getArchiveCacheDir() (string, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, DefaultArchiveCacheLocation), nil
+}
+
+func archiveCacheKey(checksum string, algo string) string {
+	return algo + "-" + strings.ToLower(checksum)
+}
+
+// checkCacheEntry makes sure the checksum and the name of an archive from the registry can't point outside
+// of the cache
+func checkCacheEntry(checksum string, algo string, name string) error {
+	if err := checkFileName(archiveCacheKey(checksum, algo)); err != nil {
+		return err
+	}
+	return checkFileName(name)
+}
+
+// lookupCachedArchive returns the path of the cached archive with the given checksum.
+// The archive is verified before reuse and dropped from the cache when it doesn't match anymore.
+func lookupCachedArchive(checksum string, algo string, name string) (string, bool) {
+	if checksum == "" || checkCacheEntry(checksum, algo, name) != nil {
+		return "", false
+	}
+
+	cacheDir, err := getArchiveCacheDir()
+	if err != nil {
+		return "", false
+	}
+	entryDir := filepath.Join(cacheDir, archiveCacheKey(checksum, algo))
+	filePath := filepath.Join(entryDir, name)
+	if _, err := os.Stat(filePath); err != nil {
+		return "", false
+	}
+
+	valid, err := verifyChecksum(filePath, checksum, algo)
+	if err != nil || !valid {
+		_ = os.RemoveAll(entryDir)
+		return "", false
+	}
+
+	touchCachedArchive(filePath)
+	return filePath, true
+}
+
+// addToArchiveCache moves a verified archive into the cache and returns its new path
+func addToArchiveCache(filePath string, checksum string, algo string, name string) (string, error) {
+	if err := checkCacheEntry(checksum, algo, name); err != nil {
+		return "", err
+	}
+	cacheDir, err := getArchiveCacheDir()
+	if err != nil {
+		return "", err
+	}
+	entryDir := filepath.Join(cacheDir, archiveCacheKey(checksum, algo))
+	if err := os.MkdirAll(entryDir, 0755); err != nil {
+		return "", err
+	}
+
+	cachedPath := filepath.Join(entryDir, name)
+	if err := os.Rename(filePath, cachedPath); err != nil {
+		return "", err
+	}
+	touchCachedArchive(cachedPath)
+
+	if err := evictArchiveCache(DefaultCacheMaxSize, cachedPath); err != nil {
+		return "", err
+	}
+	return cachedPath, nil
+}
+
+func touchCachedArchive(filePath string) {
+	now := time.Now()
+	_ = os.Chtimes(filePath, now, now)
+}
+
+// ListCachedArchives returns the cached archives, the most recently used first
+func ListCachedArchives() ([]CachedArchive, error) {
+	cacheDir, err := getArchiveCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []CachedArchive{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var archives []CachedArchive
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(cacheDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			archives = append(archives, CachedArchive{
+				Key:      entry.Name(),
+				Name:     file.Name(),
+				Path:     filepath.Join(cacheDir, entry.Name(), file.Name()),
+				Size:     info.Size(),
+				LastUsed: info.ModTime(),
+			})
+		}
+	}
+
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].LastUsed.After(archives[j].LastUsed)
+	})
+	return archives, nil
+}
+
+// ArchiveCacheSize returns the total size of the cached archives
+func ArchiveCacheSize() (int64, error) {
+	archives, err := ListCachedArchives()
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, archive := range archives {
+		size += archive.Size
+	}
+	return size, nil
+}
+
+// CleanArchiveCache removes every cached archive and partial download. It returns the number of freed bytes.
+func CleanArchiveCache() (int64, error) {
+	size, err := ArchiveCacheSize()
+	if err != nil {
+		return 0, err
+	}
+
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return 0, err
+	}
+	downloadDir := filepath.Join(cacheDir, DefaultDownloadLocation)
+	if partials, err := os.ReadDir(downloadDir); err == nil {
+		for _, partial := range partials {
+			if info, err := partial.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(cacheDir, DefaultArchiveCacheLocation)); err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(downloadDir); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// evictArchiveCache removes the least recently used archives until the cache fits in maxSize.
+// The archive at keep is never evicted.
+func evictArchiveCache(maxSize int64, keep string) error {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	archives, err := ListCachedArchives()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, archive := range archives {
+		size += archive.Size
+	}
+
+	// archives are sorted from the most to the least recently used
+	for i := len(archives) - 1; i >= 0 && size > maxSize; i-- {
+		if archives[i].Path == keep {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Dir(archives[i].Path)); err != nil {
+			return err
+		}
+		size -= archives[i].Size
+	}
+	return nil
+}
+
+// FormatBytes formats a size in bytes for humans, e.g. 1.5 GiB
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveCacheRejectsUnsafeEntries(t *testing.T) {
+	CacheHome = t.TempDir()
+	defer func() { CacheHome = "" }()
+
+	tests := []struct{ checksum, name string }{
+		{"abc", "../../../.bashrc"},
+		{"abc", ".."},
+		{"../../../tmp", "go.tar.gz"},
+	}
+	for _, test := range tests {
+		filePath := filepath.Join(t.TempDir(), "download")
+		if err := os.WriteFile(filePath, []byte("archive"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if cached, err := addToArchiveCache(filePath, test.checksum, "sha256", test.name); err == nil {
+			t.Errorf("%s %q was cached as %s", test.checksum, test.name, cached)
+		}
+		if _, ok := lookupCachedArchive(test.checksum, "sha256", test.name); ok {
+			t.Errorf("%s %q was found in the cache", test.checksum, test.name)
+		}
+	}
+}
+
+func TestArchiveCacheSurvivesRelocation(t *testing.T) {
+	CacheHome, DetoHome = t.TempDir(), t.TempDir()
+	defer func() { CacheHome, DetoHome = "", "" }()
+
+	archive := []byte("go archive")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(archive))
+	filePath := filepath.Join(t.TempDir(), "go.tar.gz")
+	if err := os.WriteFile(filePath, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := addToArchiveCache(filePath, checksum, "sha256", "go.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the install root moves, the archive is still found
+	DetoHome = t.TempDir()
+	got, ok := lookupCachedArchive(checksum, "sha256", "go.tar.gz")
+	if !ok || got != cached {
+		t.Fatalf("got %q, %v after the relocation, want %q", got, ok, cached)
+	}
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/halng/deto/configs"
+)
+
+// == In this file, we apply the config file to the package settings. == //
+
+// RegistrySources are the registry URLs tried in order, %s is replaced by the candidate
+var RegistrySources = []string{"https://raw.githubusercontent.com/halng/deto/refs/heads/main/registry/%s_versions.json"}
+
+// AutoDefault tells whether an installed version becomes the default one, see configs.InstallConfig
+var AutoDefault = configs.AutoDefaultAsk
+
+// ApplyConfig overrides the package settings with a loaded config
+func ApplyConfig(config configs.Config) error {
+	DetoHome = config.Home
+	SharedRoots = config.SharedRoots
+	CacheHome = config.Cache.Dir
+	RegistrySources = config.Registry.Sources
+	AutoDefault = config.Install.AutoDefault
+	RequireSignatures = config.Install.RequireSignatures
+	if config.Install.Parallelism > 0 {
+		DefaultParallelism = config.Install.Parallelism
+	}
+
+	UserHooks = Hooks{
+		PostInstall: config.Hooks.PostInstall,
+		PostDefault: config.Hooks.PostDefault,
+		PreRemove:   config.Hooks.PreRemove,
+	}
+	AllowRegistryHooks = config.Hooks.AllowRegistry
+	hookTimeout, err := time.ParseDuration(config.Hooks.Timeout)
+	if err != nil {
+		return err
+	}
+	HookTimeout = hookTimeout
+
+	cacheMaxSize, err := configs.ParseSize(config.Cache.MaxSize)
+	if err != nil {
+		return err
+	}
+	DefaultCacheMaxSize = cacheMaxSize
+
+	maxTotalBytes, err := configs.ParseSize(config.Install.MaxTotalSize)
+	if err != nil {
+		return err
+	}
+	maxFileBytes, err := configs.ParseSize(config.Install.MaxFileSize)
+	if err != nil {
+		return err
+	}
+	DefaultExtractLimits = ExtractLimits{
+		MaxTotalBytes: maxTotalBytes,
+		MaxFiles:      config.Install.MaxFiles,
+		MaxFileBytes:  maxFileBytes,
+		MaxPathDepth:  config.Install.MaxPathDepth,
+	}
+	return nil
+}
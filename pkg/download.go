@@ -0,0 +1,427 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// == In this file, we download archives into the download cache and resume partial downloads. == //
+
+var (
+	DefaultRetries      = 3
+	DefaultRetryBackoff = time.Second
+	// DefaultParallelism is the number of connections used to download an archive
+	DefaultParallelism = 4
+	// DefaultMinSegmentSize avoids splitting small files, a segment is never smaller than this
+	DefaultMinSegmentSize int64 = 8 << 20
+)
+
+var errReadTimeout = errors.New("no data received from the server in time")
+
+// downloadMeta is stored next to a partial download so that the next run can resume it
+type downloadMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Size         int64  `json:"size"`
+	// Segments is set for a segmented download, see segmented_download.go
+	Segments []downloadSegment `json:"segments,omitempty"`
+}
+
+// httpStatusError is returned when the server answers with an unexpected status code
+type httpStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("can not download %s. Error code %d", e.URL, e.StatusCode)
+}
+
+// Downloader downloads files into Dir. A partial download is kept as <name>.part together with
+// <name>.meta and is resumed with a Range request when the server supports it and the remote file
+// did not change (validated with ETag/Last-Modified). Otherwise, the download restarts from zero.
+// Server errors and transient network errors are retried with an exponential backoff.
+type Downloader struct {
+	Client       *http.Client
+	Dir          string
+	Retries      int
+	RetryBackoff time.Duration
+	// ReadTimeout aborts an attempt when no bytes were received for that long
+	ReadTimeout time.Duration
+	// Parallelism is the number of ranges fetched concurrently, 1 disables segmented downloads
+	Parallelism    int
+	MinSegmentSize int64
+	// OnProgress is called as bytes arrive. total is -1 when the server didn't send a Content-Length.
+	OnProgress func(downloaded int64, total int64)
+}
+
+// NewDownloader returns a Downloader that stores files in the download cache and uses the shared client
+func NewDownloader() (*Downloader, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Downloader{
+		Client:         client,
+		Dir:            filepath.Join(cacheDir, DefaultDownloadLocation),
+		Retries:        DefaultRetries,
+		RetryBackoff:   DefaultRetryBackoff,
+		ReadTimeout:    DefaultReadTimeout,
+		Parallelism:    DefaultParallelism,
+		MinSegmentSize: DefaultMinSegmentSize,
+	}, nil
+}
+
+// Download fetches url and saves it as Dir/name. It returns the path of the downloaded file.
+// Cancelling ctx stops the download and keeps the partial file for the next run.
+func (d *Downloader) Download(ctx context.Context, url string, name string) (string, error) {
+	if err := checkFileName(name); err != nil {
+		return "", err
+	}
+	backoff := d.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		filePath, err := d.download(ctx, url, name)
+		if err == nil || attempt >= d.Retries || !isRetryable(ctx, err) {
+			return filePath, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *Downloader) download(ctx context.Context, url string, name string) (string, error) {
+	if err := os.MkdirAll(d.Dir, 0755); err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(d.Dir, name)
+	partPath := dest + ".part"
+	metaPath := dest + ".meta"
+
+	meta := loadDownloadMeta(metaPath)
+	if !meta.canResume(url) {
+		// a change of the remote file would go unnoticed, the part file can't be trusted
+		_ = os.Remove(partPath)
+		_ = os.Remove(metaPath)
+		meta = nil
+	}
+	if meta != nil && len(meta.Segments) > 0 {
+		return d.downloadSegments(ctx, url, dest, meta)
+	}
+
+	var offset int64
+	if meta != nil {
+		if info, err := os.Stat(partPath); err == nil {
+			offset = info.Size()
+		}
+	}
+
+	if offset == 0 && d.Parallelism > 1 {
+		segmentedMeta, err := d.probeSegments(ctx, url)
+		if err != nil {
+			return "", err
+		}
+		if segmentedMeta != nil {
+			return d.downloadSegments(ctx, url, dest, segmentedMeta)
+		}
+	}
+
+	// the request is cancelled when the server stops sending data for ReadTimeout
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resp, offset, err := d.request(reqCtx, url, meta, offset)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// total is -1 when the size is unknown, e.g. for chunked responses
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		if _, size, err := parseContentRange(resp.Header.Get("Content-Range")); err == nil && size > 0 {
+			total = size
+		}
+	}
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// the part file already holds the whole content
+		total = offset
+	} else {
+		newMeta := downloadMeta{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Size:         total,
+		}
+		if err := saveDownloadMeta(metaPath, newMeta); err != nil {
+			return "", err
+		}
+
+		flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if offset > 0 {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(partPath, flag, 0644)
+		if err != nil {
+			return "", err
+		}
+
+		body := io.Reader(resp.Body)
+		var timedOut func() bool
+		if d.ReadTimeout > 0 {
+			body, timedOut = newIdleTimeoutReader(resp.Body, d.ReadTimeout, cancel)
+		}
+
+		pw := &progressWriter{downloaded: offset, total: total, onProgress: d.OnProgress}
+		_, err = io.Copy(file, io.TeeReader(body, pw))
+		closeErr := file.Close()
+		if err != nil {
+			// keep the part file and meta so that the next attempt can resume
+			if timedOut != nil && timedOut() && ctx.Err() == nil {
+				return "", errReadTimeout
+			}
+			return "", err
+		}
+		if closeErr != nil {
+			return "", closeErr
+		}
+	}
+
+	info, err := os.Stat(partPath)
+	if err != nil {
+		return "", err
+	}
+	if total >= 0 && info.Size() != total {
+		return "", fmt.Errorf("incomplete download: got %d of %d bytes", info.Size(), total)
+	}
+
+	if err := os.Rename(partPath, dest); err != nil {
+		return "", err
+	}
+	_ = os.Remove(metaPath)
+	return dest, nil
+}
+
+// checkFileName rejects names that aren't a plain file name, e.g. ../../.bashrc from a registry entry
+func checkFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	return nil
+}
+
+// request sends the GET request, resuming from offset when possible. It returns the response and
+// the offset the response body starts at.
+func (d *Downloader) request(ctx context.Context, url string, meta *downloadMeta, offset int64) (*http.Response, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		setIfRange(req, meta)
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the server ignored the range or the file changed, start over
+		return resp, 0, nil
+	case http.StatusPartialContent:
+		start, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if offset > 0 && err == nil && start == offset {
+			return resp, offset, nil
+		}
+		resp.Body.Close()
+		if offset == 0 {
+			return nil, 0, &httpStatusError{URL: url, StatusCode: resp.StatusCode}
+		}
+		// the server answered with a range we didn't ask for, restart without Range
+		return d.request(ctx, url, meta, 0)
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset > 0 && meta.Size == offset {
+			return resp, offset, nil
+		}
+		resp.Body.Close()
+		if offset == 0 {
+			return nil, 0, &httpStatusError{URL: url, StatusCode: resp.StatusCode}
+		}
+		return d.request(ctx, url, meta, 0)
+	default:
+		resp.Body.Close()
+		return nil, 0, &httpStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+}
+
+// canResume reports whether the partial download of url can be resumed. The server must have sent a strong
+// ETag or a Last-Modified, so that If-Range makes it send the whole file when it changed.
+func (m *downloadMeta) canResume(url string) bool {
+	if m == nil || m.URL != url {
+		return false
+	}
+	return m.ETag != "" && !strings.HasPrefix(m.ETag, "W/") || m.LastModified != ""
+}
+
+// setIfRange makes the server send the whole file when it changed since the first attempt.
+// Weak ETags are not allowed in If-Range.
+func setIfRange(req *http.Request, meta *downloadMeta) {
+	if meta.ETag != "" && !strings.HasPrefix(meta.ETag, "W/") {
+		req.Header.Set("If-Range", meta.ETag)
+	} else if meta.LastModified != "" {
+		req.Header.Set("If-Range", meta.LastModified)
+	}
+}
+
+// isRetryable reports whether a failed attempt is worth retrying: server errors, rate limiting,
+// timeouts and broken connections are; client errors, local file errors and cancellation are not.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 ||
+			statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode == http.StatusRequestTimeout
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+	var linkErr *os.LinkError
+	return !errors.As(err, &linkErr)
+}
+
+// parseContentRange parses a header like "bytes 100-199/200" and returns the start and the full size.
+// The size is -1 when the server doesn't know it.
+func parseContentRange(value string) (int64, int64, error) {
+	value, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %s", value)
+	}
+	byteRange, size, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %s", value)
+	}
+	startValue, _, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %s", value)
+	}
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if size == "*" {
+		return start, -1, nil
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, total, nil
+}
+
+func loadDownloadMeta(path string) *downloadMeta {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var meta downloadMeta
+	if err := json.Unmarshal(fileBytes, &meta); err != nil {
+		return nil
+	}
+	return &meta
+}
+
+func saveDownloadMeta(path string, meta downloadMeta) error {
+	byteData, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, byteData, 0644)
+}
+
+// idleTimeoutReader calls cancel when no Read returned data for timeout
+type idleTimeoutReader struct {
+	reader io.Reader
+	timer  *time.Timer
+	fired  chan struct{}
+	once   sync.Once
+	time   time.Duration
+}
+
+func newIdleTimeoutReader(reader io.Reader, timeout time.Duration, cancel context.CancelFunc) (io.Reader, func() bool) {
+	r := &idleTimeoutReader{reader: reader, time: timeout, fired: make(chan struct{})}
+	r.timer = time.AfterFunc(timeout, func() {
+		r.once.Do(func() { close(r.fired) })
+		cancel()
+	})
+	return r, r.timedOut
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.time)
+	}
+	if err != nil {
+		r.timer.Stop()
+	}
+	return n, err
+}
+
+func (r *idleTimeoutReader) timedOut() bool {
+	select {
+	case <-r.fired:
+		return true
+	default:
+		return false
+	}
+}
+
+// progressWriter counts the bytes written through it and reports the progress
+type progressWriter struct {
+	downloaded int64
+	total      int64
+	onProgress func(downloaded int64, total int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.downloaded += int64(len(p))
+	if pw.onProgress != nil {
+		pw.onProgress(pw.downloaded, pw.total)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestDownloader returns a Downloader without retries nor segments, so that every request is visible
+func newTestDownloader(t *testing.T, server *httptest.Server) *Downloader {
+	return &Downloader{
+		Client:       server.Client(),
+		Dir:          t.TempDir(),
+		RetryBackoff: time.Millisecond,
+		Parallelism:  1,
+	}
+}
+
+func TestDownloadResumesDroppedConnection(t *testing.T) {
+	content := bytes.Repeat([]byte("deto"), 64<<10)
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"v1"`)
+		if first {
+			// send half of the file, then drop the connection
+			w.Header().Set("Content-Length", "262144")
+			w.WriteHeader(http.StatusOK)
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	d := newTestDownloader(t, server)
+	if _, err := d.Download(context.Background(), server.URL, "file.tar.gz"); err == nil {
+		t.Fatal("expected the dropped connection to fail the download")
+	}
+
+	path, err := d.Download(context.Background(), server.URL, "file.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(content))
+	}
+	if len(ranges) != 2 || ranges[1] != "bytes=131072-" {
+		t.Fatalf("got requests with ranges %q, want a resume from byte 131072", ranges)
+	}
+}
+
+func TestDownloadStopsOnUnsatisfiableRange(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := newTestDownloader(t, server).Download(ctx, server.URL, "file.tar.gz")
+
+	var statusErr *httpStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("got %v, want a 416 status error", err)
+	}
+	if requests != 1 {
+		t.Fatalf("got %d requests, want 1", requests)
+	}
+}
+
+func TestDownloadRejectsUnsafeNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("export EVIL=1"))
+	}))
+	defer server.Close()
+
+	d := newTestDownloader(t, server)
+	d.Retries = 3
+	d.RetryBackoff = time.Hour
+	for _, name := range []string{"../../../.bashrc", "..", "a/b.tar.gz", `a\b.zip`, ""} {
+		if _, err := d.Download(context.Background(), server.URL, name); err == nil {
+			t.Errorf("%q was downloaded", name)
+		}
+	}
+}
+
+func TestDownloadRestartsWithoutValidators(t *testing.T) {
+	versions := [][]byte{bytes.Repeat([]byte("old!"), 64<<10), bytes.Repeat([]byte("new!"), 64<<10)}
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+
+		// no ETag nor Last-Modified, the content changes between the attempts
+		if first {
+			w.Header().Set("Content-Length", strconv.Itoa(len(versions[0])))
+			w.WriteHeader(http.StatusOK)
+			w.Write(versions[0][:len(versions[0])/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(versions[1]))
+	}))
+	defer server.Close()
+
+	d := newTestDownloader(t, server)
+	if _, err := d.Download(context.Background(), server.URL, "file.tar.gz"); err == nil {
+		t.Fatal("expected the dropped connection to fail the download")
+	}
+	path, err := d.Download(context.Background(), server.URL, "file.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, versions[1]) {
+		t.Fatal("the new content was spliced onto the old one")
+	}
+	if len(ranges) != 2 || ranges[1] != "" {
+		t.Fatalf("got requests with ranges %q, want a restart from zero", ranges)
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		statuses     []int
+		wantRequests int
+		wantStatus   int
+	}{
+		{"server errors then success", 3, []int{503, 500, 200}, 3, 0},
+		{"rate limited then success", 3, []int{429, 200}, 2, 0},
+		{"client error", 3, []int{404}, 1, 404},
+		{"retries exhausted", 2, []int{503, 503, 503, 503}, 3, 503},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := test.statuses[min(requests, len(test.statuses)-1)]
+				requests++
+				mu.Unlock()
+				w.WriteHeader(status)
+				w.Write([]byte("go archive"))
+			}))
+			defer server.Close()
+
+			d := newTestDownloader(t, server)
+			d.Retries = test.retries
+			_, err := d.Download(context.Background(), server.URL, "go.tar.gz")
+
+			var statusErr *httpStatusError
+			switch {
+			case test.wantStatus == 0 && err != nil:
+				t.Fatalf("got %v, want a download", err)
+			case test.wantStatus != 0 && (!errors.As(err, &statusErr) || statusErr.StatusCode != test.wantStatus):
+				t.Fatalf("got %v, want a %d status error", err, test.wantStatus)
+			}
+			if requests != test.wantRequests {
+				t.Fatalf("got %d requests, want %d", requests, test.wantRequests)
+			}
+		})
+	}
+}
+
+// stallingServer sends the start of a body, then nothing until the test ends
+func stallingServer(t *testing.T) *httptest.Server {
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", "1000")
+		w.Write(make([]byte, 100))
+		w.(http.Flusher).Flush()
+		select {
+		case <-stop:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(stop)
+		server.Close()
+	})
+	return server
+}
+
+func TestDownloadReadTimeout(t *testing.T) {
+	server := stallingServer(t)
+	d := newTestDownloader(t, server)
+	d.ReadTimeout = 50 * time.Millisecond
+
+	_, err := d.Download(context.Background(), server.URL, "go.tar.gz")
+	if !errors.Is(err, errReadTimeout) {
+		t.Fatalf("got %v, want a read timeout", err)
+	}
+	// the received bytes are kept for the next attempt
+	if info, err := os.Stat(filepath.Join(d.Dir, "go.tar.gz.part")); err != nil || info.Size() != 100 {
+		t.Fatalf("got %v, %v for the part file, want 100 bytes", info, err)
+	}
+}
+
+func TestDownloadCancel(t *testing.T) {
+	server := stallingServer(t)
+	d := newTestDownloader(t, server)
+	d.Retries = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := d.Download(ctx, server.URL, "go.tar.gz")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the download stopped after %s", elapsed)
+	}
+}
@@ -0,0 +1,520 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/halng/deto/tui"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// == In this file, we extract archives into the staging directory of an install. == //
+
+// archiveFormat is a supported archive format. Formats are detected from the first bytes of the file, so the
+// file name doesn't matter. Stream extracts the format from a reader and is nil for formats that need random
+// access, which use Extract on a file instead. Both get the name to use for the executable of single binary
+// downloads.
+type archiveFormat struct {
+	Name    string
+	Match   func(magic []byte) bool
+	Stream  func(ctx context.Context, reader io.Reader, finalDest string, binaryName string) error
+	Extract func(ctx context.Context, src string, finalDest string, binaryName string) error
+}
+
+// archiveFormats are tried in order, the first match wins
+var archiveFormats = []archiveFormat{
+	{Name: "tar.gz", Match: hasPrefix([]byte{0x1f, 0x8b}), Stream: tarStreamer(newGzipReader)},
+	{Name: "tar.xz", Match: hasPrefix([]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}), Stream: tarStreamer(newXzReader)},
+	{Name: "tar.bz2", Match: hasPrefix([]byte("BZh")), Stream: tarStreamer(newBzip2Reader)},
+	{Name: "tar.zst", Match: hasPrefix([]byte{0x28, 0xb5, 0x2f, 0xfd}), Stream: tarStreamer(newZstdReader)},
+	{Name: "zip", Match: isZip, Extract: decompressZipArchive},
+	{Name: "tar", Match: isTar, Stream: tarStreamer(newPlainReader)},
+	{Name: "binary", Match: isExecutable, Stream: installBinary},
+}
+
+// magicSize is the number of bytes needed to detect every format
+const magicSize = 512
+
+// detectArchiveFormat reads the first bytes of a file and returns its format
+func detectArchiveFormat(fileName string) (archiveFormat, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return archiveFormat{}, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, magicSize)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return archiveFormat{}, err
+	}
+
+	format, ok := matchArchiveFormat(magic[:n])
+	if !ok {
+		return archiveFormat{}, fmt.Errorf("unsupported file format: %s", fileName)
+	}
+	return format, nil
+}
+
+func matchArchiveFormat(magic []byte) (archiveFormat, bool) {
+	for _, format := range archiveFormats {
+		if format.Match(magic) {
+			return format, true
+		}
+	}
+	return archiveFormat{}, false
+}
+
+// extract extracts the file src with the format
+func (format archiveFormat) extract(ctx context.Context, src string, finalDest string, binaryName string) error {
+	if format.Extract != nil {
+		return format.Extract(ctx, src, finalDest, binaryName)
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return format.Stream(ctx, file, finalDest, binaryName)
+}
+
+func hasPrefix(prefix []byte) func([]byte) bool {
+	return func(magic []byte) bool {
+		return bytes.HasPrefix(magic, prefix)
+	}
+}
+
+func isZip(magic []byte) bool {
+	// local file header, or the end of central directory of an empty archive
+	return bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06"))
+}
+
+func isTar(magic []byte) bool {
+	// POSIX and GNU tar headers have "ustar" at offset 257
+	return len(magic) >= 262 && bytes.Equal(magic[257:262], []byte("ustar"))
+}
+
+// isExecutable matches ELF, Mach-O, PE executables and scripts
+func isExecutable(magic []byte) bool {
+	executableMagics := [][]byte{
+		[]byte("\x7fELF"),
+		{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+		{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+		{0xca, 0xfe, 0xba, 0xbe},
+		[]byte("MZ"),
+		[]byte("#!"),
+	}
+	for _, executableMagic := range executableMagics {
+		if bytes.HasPrefix(magic, executableMagic) {
+			return true
+		}
+	}
+	return false
+}
+
+func newGzipReader(reader io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(reader)
+}
+
+func newXzReader(reader io.Reader) (io.ReadCloser, error) {
+	xzReader, err := xz.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(xzReader), nil
+}
+
+func newBzip2Reader(reader io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(reader)), nil
+}
+
+func newZstdReader(reader io.Reader) (io.ReadCloser, error) {
+	zstdReader, err := zstd.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return zstdReader.IOReadCloser(), nil
+}
+
+func newPlainReader(reader io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(reader), nil
+}
+
+// tarStreamer extracts a tarball compressed with the given decompressor
+func tarStreamer(newReader func(io.Reader) (io.ReadCloser, error)) func(context.Context, io.Reader, string, string) error {
+	return func(ctx context.Context, compressed io.Reader, finalDest string, _ string) error {
+		reader, err := newReader(compressed)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		return extractTar(ctx, tar.NewReader(reader), finalDest)
+	}
+}
+
+func decompressZipArchive(ctx context.Context, src string, finalDest string, _ string) error {
+	return decompressZip(ctx, src, finalDest)
+}
+
+// installBinary places a single executable download into bin/ with the executable bit set
+func installBinary(_ context.Context, reader io.Reader, finalDest string, binaryName string) error {
+	if runtime.GOOS == "windows" && filepath.Ext(binaryName) == "" {
+		binaryName += ".exe"
+	}
+	binDir := filepath.Join(finalDest, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+
+	budget := newExtractBudget()
+	if err := budget.addEntry(binaryName, 0); err != nil {
+		return err
+	}
+	return writeRegularFile(filepath.Join(binDir, binaryName), budget.reader(binaryName, reader), 0755, time.Now())
+}
+
+func extractFile(ctx context.Context, fileName string, finalDest string, binaryName string) error {
+	tui.Clear()
+	msg := fmt.Sprintf("Extracting from %s to %s ...", fileName, finalDest)
+	modelSpinner := tui.InitialSpinnerModel()
+	modelSpinner.Prompt = msg
+	p := tea.NewProgram(modelSpinner, tui.ProgramOptions()...)
+	go func() {
+		if _, err := p.Run(); err != nil {
+			fmt.Println("Error running spinner:", err)
+			os.Exit(1)
+		}
+	}()
+
+	defer p.Send(tea.Quit())
+
+	format, err := detectArchiveFormat(fileName)
+	if err != nil {
+		return err
+	}
+	return format.extract(ctx, fileName, finalDest, binaryName)
+}
+
+// extractedDir is a directory whose mode and mtime are applied once all its entries are written
+type extractedDir struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// extractTar writes the entries of a tar stream into finalDest, preserving permission bits, mtimes,
+// symlinks and hard links. PAX and GNU long names are resolved by archive/tar. Links must stay inside
+// finalDest and no entry is ever written through a symlink. Entries count against the limits of limits.go.
+func extractTar(ctx context.Context, tarReader *tar.Reader, finalDest string) error {
+	finalDest = filepath.Clean(finalDest)
+	budget := newExtractBudget()
+	var dirs []extractedDir
+
+	// Iterate over the tar file entries
+	for {
+		// stop between entries when the install is interrupted
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break // End of archive
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			// PAX global headers only carry metadata
+			continue
+		}
+		if err := budget.addEntry(header.Name, header.Size); err != nil {
+			return err
+		}
+
+		targetPath, err := archiveEntryPath(finalDest, header.Name)
+		if err != nil {
+			return err
+		}
+		if targetPath != finalDest {
+			if err := checkParentDirs(finalDest, targetPath, header.Name); err != nil {
+				return err
+			}
+		}
+
+		mode := header.FileInfo().Mode().Perm()
+
+		// Check the type of entry
+		switch header.Typeflag {
+		case tar.TypeDir:
+			// the owner must be able to write into the directory until the extraction is done
+			if err := os.MkdirAll(targetPath, mode|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, extractedDir{path: targetPath, mode: mode, modTime: header.ModTime})
+		case tar.TypeReg:
+			if err := writeRegularFile(targetPath, budget.reader(header.Name, tarReader), mode, header.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := linkInside(finalDest, targetPath, header.Name, header.Linkname); err != nil {
+				return err
+			}
+			if err := replaceWith(targetPath, func() error { return os.Symlink(header.Linkname, targetPath) }); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			// hard links name an earlier entry of the archive
+			linkTarget, err := archiveEntryPath(finalDest, header.Linkname)
+			if err != nil {
+				return &entryError{Name: header.Name, Reason: fmt.Sprintf("the link target %s is outside of the destination", header.Linkname)}
+			}
+			if err := checkParentDirs(finalDest, linkTarget, header.Name); err != nil {
+				return err
+			}
+			if err := replaceWith(targetPath, func() error { return os.Link(linkTarget, targetPath) }); err != nil {
+				return err
+			}
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			return &entryError{Name: header.Name, Reason: "device files and FIFOs are not allowed"}
+		default:
+			log.Printf("Unable to handle file type %c in tar file", header.Typeflag)
+		}
+	}
+
+	return applyDirModes(dirs)
+}
+
+// decompressZip extracts a zip archive with the same path protections as tarballs. Unix permissions and
+// symlinks stored in the external attributes are preserved, entries made on Windows get the default modes.
+func decompressZip(ctx context.Context, src, finalDest string) error {
+	zipReader, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zipReader.Close()
+
+	finalDest = filepath.Clean(finalDest)
+	budget := newExtractBudget()
+	var dirs []extractedDir
+
+	for _, entry := range zipReader.File {
+		// stop between entries when the install is interrupted
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := budget.addEntry(entry.Name, int64(entry.UncompressedSize64)); err != nil {
+			return err
+		}
+
+		targetPath, err := archiveEntryPath(finalDest, entry.Name)
+		if err != nil {
+			return err
+		}
+		if targetPath != finalDest {
+			if err := checkParentDirs(finalDest, targetPath, entry.Name); err != nil {
+				return err
+			}
+		}
+
+		mode := entry.Mode()
+		if entry.CreatorVersion>>8 != zipCreatorUnix {
+			// MS-DOS attributes only tell read-only or not, archive/zip makes everything world-writable
+			mode &^= 0022
+		}
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(targetPath, mode.Perm()|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, extractedDir{path: targetPath, mode: mode.Perm(), modTime: entry.Modified})
+		case mode&os.ModeSymlink != 0:
+			// the content of a symlink entry is its target
+			linkName, err := readZipEntry(entry)
+			if err != nil {
+				return err
+			}
+			if err := linkInside(finalDest, targetPath, entry.Name, linkName); err != nil {
+				return err
+			}
+			if err := replaceWith(targetPath, func() error { return os.Symlink(linkName, targetPath) }); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			if err := writeZipEntry(entry, targetPath, mode.Perm(), budget); err != nil {
+				return err
+			}
+		case mode&(os.ModeDevice|os.ModeNamedPipe|os.ModeSocket) != 0:
+			return &entryError{Name: entry.Name, Reason: "device files and FIFOs are not allowed"}
+		default:
+			log.Printf("Unable to handle file mode %s of %s in zip file", mode, entry.Name)
+		}
+	}
+
+	return applyDirModes(dirs)
+}
+
+// zipCreatorUnix is the "version made by" host of zip entries whose external attributes hold Unix modes
+const zipCreatorUnix = 3
+
+func readZipEntry(entry *zip.File) (string, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, 4096))
+	return string(content), err
+}
+
+func writeZipEntry(entry *zip.File, targetPath string, mode os.FileMode, budget *extractBudget) error {
+	reader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return writeRegularFile(targetPath, budget.reader(entry.Name, reader), mode, entry.Modified)
+}
+
+// applyDirModes applies the directory modes deepest first, so that read-only directories don't block
+// their children
+func applyDirModes(dirs []extractedDir) error {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+		_ = os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime)
+	}
+	return nil
+}
+
+// archiveEntryPath returns where an archive entry is extracted, rejecting absolute names and names that
+// escape finalDest
+func archiveEntryPath(finalDest string, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", &entryError{Name: name, Reason: "the path is outside of the destination"}
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return "", &entryError{Name: name, Reason: "the path is outside of the destination"}
+		}
+	}
+
+	// Construct the full file path
+	targetPath := filepath.Clean(filepath.Join(finalDest, name))
+
+	// Ensure the target path is within the final destination
+	if targetPath != finalDest && !strings.HasPrefix(targetPath, finalDest+string(os.PathSeparator)) {
+		return "", &entryError{Name: name, Reason: "the path is outside of the destination"}
+	}
+	return targetPath, nil
+}
+
+// checkParentDirs makes sure the parent directories of targetPath resolve inside finalDest, so that a
+// symlink extracted earlier can't redirect an entry out of the destination
+func checkParentDirs(finalDest string, targetPath string, name string) error {
+	parent := filepath.Dir(targetPath)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	realDest, err := filepath.EvalSymlinks(finalDest)
+	if err != nil {
+		return err
+	}
+	realParent, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return err
+	}
+	if realParent != realDest && !strings.HasPrefix(realParent, realDest+string(os.PathSeparator)) {
+		return &entryError{Name: name, Reason: "a parent directory resolves outside of the destination"}
+	}
+	return nil
+}
+
+// linkInside rejects symlinks whose target is absolute or resolves outside of finalDest. The target is
+// resolved through the symlinks extracted earlier, e.g. a/.. is not the parent of a when a is a link.
+func linkInside(finalDest string, targetPath string, name string, linkName string) error {
+	if filepath.IsAbs(linkName) || strings.HasPrefix(linkName, "/") {
+		return &entryError{Name: name, Reason: fmt.Sprintf("the link target %s is absolute", linkName)}
+	}
+
+	realDest, err := filepath.EvalSymlinks(finalDest)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Dir(targetPath))
+	if err != nil {
+		return err
+	}
+	for _, part := range strings.Split(filepath.ToSlash(linkName), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		resolved = filepath.Join(resolved, part)
+		if info, err := os.Lstat(resolved); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if resolved, err = filepath.EvalSymlinks(resolved); err != nil {
+				return &entryError{Name: name, Reason: fmt.Sprintf("the link target %s can't be resolved", linkName)}
+			}
+		}
+	}
+	if resolved != realDest && !strings.HasPrefix(resolved, realDest+string(os.PathSeparator)) {
+		return &entryError{Name: name, Reason: fmt.Sprintf("the link target %s is outside of the destination", linkName)}
+	}
+	return nil
+}
+
+// replaceWith removes whatever exists at path, then creates the new entry
+func replaceWith(path string, create func() error) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return create()
+}
+
+// writeRegularFile writes the content of a file entry with its permission bits and mtime. An existing
+// entry at path (e.g. a symlink from the same archive) is replaced rather than written through.
+func writeRegularFile(path string, reader io.Reader, mode os.FileMode, modTime time.Time) error {
+	return replaceWith(path, func() error {
+		outFile, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode|0200)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(outFile, reader); err != nil {
+			outFile.Close()
+			return err
+		}
+		if err := outFile.Close(); err != nil {
+			return err
+		}
+		// the umask may have dropped some bits at creation
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+		return os.Chtimes(path, modTime, modTime)
+	})
+}
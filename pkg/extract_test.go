@@ -0,0 +1,316 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+type tarTestEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+}
+
+func extractTestTar(t *testing.T, entries []tarTestEntry) error {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Linkname: entry.linkname, Mode: 0755}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return extractTar(context.Background(), tar.NewReader(&buf), t.TempDir())
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarTestEntry
+		unsafe  bool
+	}{
+		{
+			name: "relative link inside",
+			entries: []tarTestEntry{
+				{name: "lib/", typeflag: tar.TypeDir},
+				{name: "bin/", typeflag: tar.TypeDir},
+				{name: "bin/java", typeflag: tar.TypeSymlink, linkname: "../lib/java"},
+			},
+		},
+		{
+			name: "link through a link to a sibling",
+			entries: []tarTestEntry{
+				{name: "sub/", typeflag: tar.TypeDir},
+				{name: "l1", typeflag: tar.TypeSymlink, linkname: "sub"},
+				{name: "l2", typeflag: tar.TypeSymlink, linkname: "l1/../sub"},
+			},
+		},
+		{
+			name:    "parent of the destination",
+			entries: []tarTestEntry{{name: "l1", typeflag: tar.TypeSymlink, linkname: ".."}},
+			unsafe:  true,
+		},
+		{
+			name: "dot dot through a link to the destination",
+			entries: []tarTestEntry{
+				{name: "l1", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "l2", typeflag: tar.TypeSymlink, linkname: "l1/.."},
+			},
+			unsafe: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := extractTestTar(t, test.entries)
+			if test.unsafe && !errors.Is(err, errUnsafeEntry) {
+				t.Fatalf("got %v, want an unsafe entry error", err)
+			}
+			if !test.unsafe && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+type zipTestEntry struct {
+	name    string
+	mode    os.FileMode
+	content string
+}
+
+func writeTestZip(t *testing.T, entries []zipTestEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		if entry.mode != 0 {
+			// the Unix mode goes into the external attributes, the way zip on Linux and macOS stores it
+			header.SetMode(entry.mode)
+		}
+		entryWriter, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entryWriter.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDecompressZip(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []zipTestEntry
+		unsafe  bool
+		check   func(t *testing.T, dest string)
+	}{
+		{
+			name: "unix modes",
+			entries: []zipTestEntry{
+				{name: "jdk/", mode: os.ModeDir | 0755},
+				{name: "jdk/bin/", mode: os.ModeDir | 0755},
+				{name: "jdk/bin/java", mode: 0755, content: "#!/bin/sh\n"},
+				{name: "jdk/release", mode: 0644, content: "JAVA_VERSION=21"},
+			},
+			check: func(t *testing.T, dest string) {
+				checkTestMode(t, filepath.Join(dest, "jdk/bin/java"), 0755)
+				checkTestMode(t, filepath.Join(dest, "jdk/release"), 0644)
+				checkTestMode(t, filepath.Join(dest, "jdk/bin"), os.ModeDir|0755)
+			},
+		},
+		{
+			name:    "entries made on windows are not world-writable",
+			entries: []zipTestEntry{{name: "go/VERSION", content: "go1.23.2"}},
+			check: func(t *testing.T, dest string) {
+				info, err := os.Stat(filepath.Join(dest, "go/VERSION"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm()&0022 != 0 {
+					t.Fatalf("got mode %s, want no write bit for group and others", info.Mode())
+				}
+			},
+		},
+		{
+			name: "symlink inside",
+			entries: []zipTestEntry{
+				{name: "lib/java", mode: 0755, content: "binary"},
+				{name: "bin/java", mode: os.ModeSymlink | 0777, content: "../lib/java"},
+			},
+			check: func(t *testing.T, dest string) {
+				if target, err := os.Readlink(filepath.Join(dest, "bin/java")); err != nil || target != "../lib/java" {
+					t.Fatalf("got link %q, %v, want ../lib/java", target, err)
+				}
+			},
+		},
+		{
+			name:    "dot dot",
+			entries: []zipTestEntry{{name: "../evil", mode: 0644, content: "x"}},
+			unsafe:  true,
+		},
+		{
+			name:    "absolute path",
+			entries: []zipTestEntry{{name: "/tmp/evil", mode: 0644, content: "x"}},
+			unsafe:  true,
+		},
+		{
+			name:    "symlink outside",
+			entries: []zipTestEntry{{name: "etc", mode: os.ModeSymlink | 0777, content: "/etc"}},
+			unsafe:  true,
+		},
+		{
+			name:    "named pipe",
+			entries: []zipTestEntry{{name: "fifo", mode: os.ModeNamedPipe | 0644}},
+			unsafe:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dest := t.TempDir()
+			err := decompressZip(context.Background(), writeTestZip(t, test.entries), dest)
+			if test.unsafe {
+				if !errors.Is(err, errUnsafeEntry) {
+					t.Fatalf("got %v, want an unsafe entry error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, dest)
+		})
+	}
+}
+
+func checkTestMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode() & (os.ModeDir | os.ModePerm); got != want {
+		t.Fatalf("%s: got mode %s, want %s", filepath.Base(path), got, want)
+	}
+}
+
+// testTarball is a tarball with bin/tool, the content of the archives of TestArchiveFormats
+func testTarball(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	content := []byte("tool")
+	if err := writer.WriteHeader(&tar.Header{Name: "bin/tool", Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testBzip2Tarball is testTarball compressed by bzip2 -9, the standard library has no bzip2 writer
+const testBzip2Tarball = "QlpoOTFBWSZTWcg8EN0AAHh7kMmAAEFAAPeAAEBwJZ4ABAAACCAAVCUkaANqaPUD1MgklPRA0NADQUPuZRdCB4pCRjCbE6SusQIYTB3N0I3htAS0Zt1XlcU4bxx4ERiwec6RKfVqqIgfi7kinChIZB4IboA="
+
+func compressTest(t *testing.T, content []byte, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, err := newWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveFormats(t *testing.T) {
+	tarball := testTarball(t)
+	bzip2Tarball, err := base64.StdEncoding.DecodeString(testBzip2Tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipArchive, err := os.ReadFile(writeTestZip(t, []zipTestEntry{{name: "bin/tool", mode: 0755, content: "tool"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+		tool    string
+	}{
+		{"gzip", compressTest(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }), "tar.gz", "tool"},
+		{"xz", compressTest(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) }), "tar.xz", "tool"},
+		{"bzip2", bzip2Tarball, "tar.bz2", "tool"},
+		{"zstd", compressTest(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }), "tar.zst", "tool"},
+		{"zip", zipArchive, "zip", "tool"},
+		{"tar", tarball, "tar", "tool"},
+		{"elf binary", []byte("\x7fELF\x02\x01\x01"), "binary", "\x7fELF\x02\x01\x01"},
+		{"script", []byte("#!/bin/sh\necho tool\n"), "binary", "#!/bin/sh\necho tool\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the name doesn't match the format, only the content counts
+			src := writeTestFile(t, "download.bin", string(test.content))
+			format, err := detectArchiveFormat(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format.Name != test.want {
+				t.Fatalf("got format %s, want %s", format.Name, test.want)
+			}
+
+			dest := t.TempDir()
+			if err := format.extract(context.Background(), src, dest, "tool"); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := os.ReadFile(filepath.Join(dest, "bin", "tool")); err != nil || string(got) != test.tool {
+				t.Fatalf("got bin/tool %q, %v, want %q", got, err, test.tool)
+			}
+			checkTestMode(t, filepath.Join(dest, "bin", "tool"), 0755)
+		})
+	}
+
+	for _, content := range []string{"", "<html>a captive portal page</html>"} {
+		if format, err := detectArchiveFormat(writeTestFile(t, "download.tar.gz", content)); err == nil {
+			t.Errorf("%q was detected as %s", content, format.Name)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// == In this file, we run the hooks of installs, default changes and removals. == //
+// Hooks are shell commands from the config file (hooks.post_install, ...) or from the registry entry of a
+// version, which only run when hooks.allow_registry is set. They get the version in DETO_CANDIDATE,
+// DETO_VERSION, DETO_HOME_DIR and DETO_ROOT, and run in the version directory. A failing post hook doesn't undo
+// anything, it is reported; a failing pre_remove hook stops the removal.
+
+const (
+	HookPostInstall = "post_install"
+	HookPostDefault = "post_default"
+	HookPreRemove   = "pre_remove"
+)
+
+// Hooks are the commands run for each event
+type Hooks struct {
+	PostInstall []string `json:"post_install,omitempty"`
+	PostDefault []string `json:"post_default,omitempty"`
+	PreRemove   []string `json:"pre_remove,omitempty"`
+}
+
+// UserHooks are the hooks of the config file
+var UserHooks Hooks
+
+// AllowRegistryHooks runs the hooks of registry entries too
+var AllowRegistryHooks = false
+
+// HookTimeout stops hooks that run longer
+var HookTimeout = 10 * time.Minute
+
+// HookResult is the outcome of a hook
+type HookResult struct {
+	Event    string
+	Command  string
+	Duration time.Duration
+	Err      error
+}
+
+func (h *Hooks) commands(event string) []string {
+	if h == nil {
+		return nil
+	}
+	switch event {
+	case HookPostInstall:
+		return h.PostInstall
+	case HookPostDefault:
+		return h.PostDefault
+	case HookPreRemove:
+		return h.PreRemove
+	}
+	return nil
+}
+
+// runHooks runs the hooks of an event for an installation, the user hooks first
+func runHooks(event string, candidate string, installation Installation) []HookResult {
+	commands := UserHooks.commands(event)
+	if AllowRegistryHooks {
+		commands = slices.Concat(commands, installation.Hooks.commands(event))
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	versionDir, err := installationDir(installation)
+	if err != nil {
+		return []HookResult{{Event: event, Err: err}}
+	}
+	homeDir := filepath.Join(versionDir, filepath.FromSlash(installation.Home))
+	root, err := RootDir()
+	if err != nil {
+		return []HookResult{{Event: event, Err: err}}
+	}
+	env := append(os.Environ(),
+		"DETO_HOOK="+event,
+		"DETO_CANDIDATE="+candidate,
+		"DETO_VERSION="+installation.Version,
+		"DETO_HOME_DIR="+homeDir,
+		"DETO_ROOT="+root,
+	)
+
+	var results []HookResult
+	for _, command := range commands {
+		start := time.Now()
+		err := runHook(command, versionDir, env)
+		results = append(results, HookResult{Event: event, Command: command, Duration: time.Since(start), Err: err})
+	}
+	return results
+}
+
+func runHook(command string, dir string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+
+	shell := []string{"sh", "-c"}
+	if runtime.GOOS == "windows" {
+		shell = []string{"cmd", "/C"}
+	}
+	hookCmd := exec.CommandContext(ctx, shell[0], append(shell[1:], command)...)
+	hookCmd.Dir = dir
+	hookCmd.Env = env
+	hookCmd.Stdout, hookCmd.Stderr = os.Stdout, os.Stderr
+	err := hookCmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", HookTimeout)
+	}
+	return err
+}
+
+// printHookResults prints the summary of the hooks and returns an error when one of them failed
+func printHookResults(results []HookResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	var errs []error
+	fmt.Println("Hooks:")
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("  failed  %s: %s (%s)\n", result.Event, result.Command, result.Err)
+			errs = append(errs, fmt.Errorf("%s hook %q: %w", result.Event, result.Command, result.Err))
+			continue
+		}
+		fmt.Printf("  ok      %s: %s (%s)\n", result.Event, result.Command, result.Duration.Round(time.Millisecond))
+	}
+	return errors.Join(errs...)
+}
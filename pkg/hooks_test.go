@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the test are sh commands")
+	}
+
+	tests := []struct {
+		name          string
+		event         string
+		userHooks     Hooks
+		registryHooks *Hooks
+		allowRegistry bool
+		timeout       time.Duration
+		wantLog       string
+		wantErrs      []string
+	}{
+		{
+			name:      "user hooks",
+			userHooks: Hooks{PostInstall: []string{`echo "user $DETO_HOOK $DETO_CANDIDATE $DETO_VERSION" >> "$LOG"`}},
+			wantLog:   "user post_install go 1.0\n",
+			wantErrs:  []string{""},
+		},
+		{
+			name:          "registry hooks need allow_registry",
+			userHooks:     Hooks{PostInstall: []string{`echo user >> "$LOG"`}},
+			registryHooks: &Hooks{PostInstall: []string{`echo registry >> "$LOG"`}},
+			wantLog:       "user\n",
+			wantErrs:      []string{""},
+		},
+		{
+			name:          "user hooks run first",
+			userHooks:     Hooks{PostInstall: []string{`echo user >> "$LOG"`}},
+			registryHooks: &Hooks{PostInstall: []string{`echo registry >> "$LOG"`}},
+			allowRegistry: true,
+			wantLog:       "user\nregistry\n",
+			wantErrs:      []string{"", ""},
+		},
+		{
+			name:      "version directory and home",
+			userHooks: Hooks{PostInstall: []string{`echo "$(pwd) $DETO_HOME_DIR" >> "$LOG"`}},
+			wantLog:   "{dir} {dir}\n",
+			wantErrs:  []string{""},
+		},
+		{
+			name:      "failing hook doesn't stop the next ones",
+			userHooks: Hooks{PostInstall: []string{"exit 3", `echo next >> "$LOG"`}},
+			wantLog:   "next\n",
+			wantErrs:  []string{"exit status 3", ""},
+		},
+		{
+			name:      "timeout",
+			userHooks: Hooks{PostInstall: []string{"sleep 1"}},
+			timeout:   100 * time.Millisecond,
+			wantErrs:  []string{"timed out"},
+		},
+		{
+			name:      "failing pre_remove",
+			event:     HookPreRemove,
+			userHooks: Hooks{PreRemove: []string{`echo "$DETO_HOOK" >> "$LOG"; exit 1`}},
+			wantLog:   "pre_remove\n",
+			wantErrs:  []string{"exit status 1"},
+		},
+		{
+			name:      "other event",
+			userHooks: Hooks{PostDefault: []string{`echo default >> "$LOG"`}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			DetoHome = t.TempDir()
+			logFile := filepath.Join(t.TempDir(), "log")
+			t.Setenv("LOG", logFile)
+			UserHooks, AllowRegistryHooks = test.userHooks, test.allowRegistry
+			if test.timeout != 0 {
+				HookTimeout = test.timeout
+			}
+			defer func() {
+				DetoHome, UserHooks, AllowRegistryHooks, HookTimeout = "", Hooks{}, false, 10*time.Minute
+			}()
+
+			installation := Installation{Version: "1.0", Path: installPath("go", "1.0"), Hooks: test.registryHooks}
+			versionDir, err := installationDir(installation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(versionDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			event := test.event
+			if event == "" {
+				event = HookPostInstall
+			}
+			results := runHooks(event, "go", installation)
+			if len(results) != len(test.wantErrs) {
+				t.Fatalf("got %d results, want %d", len(results), len(test.wantErrs))
+			}
+			for i, result := range results {
+				if test.wantErrs[i] == "" && result.Err != nil ||
+					test.wantErrs[i] != "" && (result.Err == nil || !strings.Contains(result.Err.Error(), test.wantErrs[i])) {
+					t.Errorf("%s: got %v, want %q", result.Command, result.Err, test.wantErrs[i])
+				}
+			}
+			if err := printHookResults(results); (err != nil) != (strings.Join(test.wantErrs, "") != "") {
+				t.Errorf("got %v from the summary", err)
+			}
+
+			got, _ := os.ReadFile(logFile)
+			realDir, err := filepath.EvalSymlinks(versionDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.ReplaceAll(test.wantLog, "{dir}", realDir); string(got) != want {
+				t.Fatalf("got hook output %q, want %q", got, want)
+			}
+		})
+	}
+}
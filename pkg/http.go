@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/halng/deto/configs"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// == In this file, we build the HTTP client shared by every request of deto. == //
+
+var (
+	DefaultConnectTimeout = 30 * time.Second
+	// DefaultReadTimeout is the longest time we wait for response headers or for the next bytes of a body
+	DefaultReadTimeout = 60 * time.Second
+)
+
+var (
+	sharedClient     *http.Client
+	sharedClientErr  error
+	sharedClientOnce sync.Once
+)
+
+// httpClient returns the client built from the network settings of the config file
+func httpClient() (*http.Client, error) {
+	sharedClientOnce.Do(func() {
+		network, err := configs.LoadNetwork()
+		if err != nil {
+			sharedClientErr = fmt.Errorf("invalid network config: %w", err)
+			return
+		}
+		sharedClient, sharedClientErr = NewHTTPClient(network)
+	})
+	return sharedClient, sharedClientErr
+}
+
+// NewHTTPClient returns a client with connect and read timeouts, the proxies, CA bundles, credentials and
+// headers of network. There is no overall timeout, large downloads are bounded by the idle read timeout of the
+// Downloader instead.
+func NewHTTPClient(network configs.NetworkConfig) (*http.Client, error) {
+	dialer := &net.Dialer{
+		Timeout:   DefaultConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = DefaultConnectTimeout
+	transport.ResponseHeaderTimeout = DefaultReadTimeout
+	transport.Proxy = proxyFunc(network)
+
+	if len(network.CABundles) > 0 {
+		rootCAs, err := loadCABundles(network.CABundles)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}
+	}
+
+	netrcPath := network.Netrc
+	if netrcPath == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		netrcPath = filepath.Join(userHome, ".netrc")
+	}
+	netrc := map[string]netrcEntry{}
+	if netrcPath != "off" {
+		var err error
+		if netrc, err = parseNetrc(netrcPath); err != nil {
+			return nil, fmt.Errorf("can not read %s: %w", netrcPath, err)
+		}
+	}
+
+	return &http.Client{
+		Transport: &authTransport{
+			base:    transport,
+			network: network,
+			netrc:   netrc,
+		},
+	}, nil
+}
+
+// proxyFunc uses the proxies of the config file and falls back to the proxy env variables
+func proxyFunc(network configs.NetworkConfig) func(*http.Request) (*url.URL, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if network.HTTPProxy != "" {
+		proxyConfig.HTTPProxy = network.HTTPProxy
+	}
+	if network.HTTPSProxy != "" {
+		proxyConfig.HTTPSProxy = network.HTTPSProxy
+	}
+	if network.NoProxy != "" {
+		proxyConfig.NoProxy = network.NoProxy
+	}
+
+	proxy := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// loadCABundles returns the system roots plus the certificates of the given PEM files
+func loadCABundles(paths []string) (*x509.CertPool, error) {
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+
+	for _, path := range paths {
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("can not read CA bundle: %w", err)
+		}
+		if !rootCAs.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("no certificate found in CA bundle %s", path)
+		}
+	}
+	return rootCAs, nil
+}
+
+// authTransport adds the configured headers and the credentials of the request host. Credentials are
+// resolved for every request, so a redirect to another host never receives them.
+type authTransport struct {
+	base    http.RoundTripper
+	network configs.NetworkConfig
+	netrc   map[string]netrcEntry
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range t.network.Headers {
+		req.Header.Set(key, value)
+	}
+
+	host := req.URL.Hostname()
+	hostConfig, ok := t.hostConfig(req.URL)
+	if ok {
+		for key, value := range hostConfig.Headers {
+			req.Header.Set(key, value)
+		}
+	}
+
+	if req.Header.Get("Authorization") == "" {
+		switch {
+		case ok && hostConfig.Token != "":
+			req.Header.Set("Authorization", "Bearer "+hostConfig.Token)
+		case ok && hostConfig.Username != "":
+			req.SetBasicAuth(hostConfig.Username, hostConfig.Password)
+		default:
+			if entry, found := t.netrc[host]; found && entry.Login != "" {
+				req.SetBasicAuth(entry.Login, entry.Password)
+			} else if entry, found := t.netrc[""]; found && entry.Login != "" {
+				req.SetBasicAuth(entry.Login, entry.Password)
+			}
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// hostConfig finds the settings of a host, matching either "host" or "host:port"
+func (t *authTransport) hostConfig(u *url.URL) (configs.HostConfig, bool) {
+	for _, hostConfig := range t.network.Hosts {
+		if strings.EqualFold(hostConfig.Host, u.Hostname()) || strings.EqualFold(hostConfig.Host, u.Host) {
+			return hostConfig, true
+		}
+	}
+	return configs.HostConfig{}, false
+}
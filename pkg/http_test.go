@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"encoding/pem"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/halng/deto/configs"
+)
+
+// headerServer answers with the Authorization and X-Team headers it received
+func headerServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Team")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestHTTPClientCredentials(t *testing.T) {
+	server := headerServer(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	netrc := writeTestFile(t, "netrc", "machine 127.0.0.1 login netrc-user password netrc-pass\ndefault login anon password guest\n")
+
+	tests := []struct {
+		name    string
+		network configs.NetworkConfig
+		want    string
+	}{
+		{"nothing", configs.NetworkConfig{Netrc: "off"}, "|"},
+		{"token", configs.NetworkConfig{Netrc: "off", Hosts: []configs.HostConfig{{Host: "127.0.0.1", Token: "tok"}}}, "Bearer tok|"},
+		{"basic auth with port", configs.NetworkConfig{Netrc: "off", Hosts: []configs.HostConfig{{Host: host, Username: "bob", Password: "pw"}}}, "Basic Ym9iOnB3|"},
+		{"other host", configs.NetworkConfig{Netrc: "off", Hosts: []configs.HostConfig{{Host: "artifacts.corp", Token: "tok"}}}, "|"},
+		{"netrc machine", configs.NetworkConfig{Netrc: netrc}, "Basic bmV0cmMtdXNlcjpuZXRyYy1wYXNz|"},
+		{"host wins over netrc", configs.NetworkConfig{Netrc: netrc, Hosts: []configs.HostConfig{{Host: "127.0.0.1", Token: "tok"}}}, "Bearer tok|"},
+		{"headers", configs.NetworkConfig{Netrc: "off", Headers: map[string]string{"X-Team": "platform"}}, "|platform"},
+		{"host headers", configs.NetworkConfig{Netrc: "off", Headers: map[string]string{"X-Team": "platform"},
+			Hosts: []configs.HostConfig{{Host: "127.0.0.1", Headers: map[string]string{"X-Team": "build"}}}}, "|build"},
+		{"authorization header wins", configs.NetworkConfig{Netrc: netrc, Headers: map[string]string{"Authorization": "Custom abc"}}, "Custom abc|"},
+	}
+	for _, test := range tests {
+		client, err := NewHTTPClient(test.network)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := get(t, client, server.URL); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHTTPClientDropsCredentialsOnRedirect(t *testing.T) {
+	target := headerServer(t)
+	// the same server under another host name
+	otherHost := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	origin := httptest.NewServer(http.RedirectHandler(otherHost, http.StatusFound))
+	defer origin.Close()
+
+	client, err := NewHTTPClient(configs.NetworkConfig{Netrc: "off", Hosts: []configs.HostConfig{{Host: "127.0.0.1", Token: "tok"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, client, origin.URL); got != "|" {
+		t.Fatalf("got %q at the redirect target, want no credentials", got)
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		t.Setenv(key, "")
+	}
+	network := configs.NetworkConfig{
+		HTTPProxy:  "http://proxy.corp:3128",
+		HTTPSProxy: "socks5://proxy.corp:1080",
+		NoProxy:    "localhost,.corp",
+	}
+	tests := []struct{ url, want string }{
+		{"http://go.dev/dl/", "http://proxy.corp:3128"},
+		{"https://go.dev/dl/", "socks5://proxy.corp:1080"},
+		{"https://artifacts.corp/go/", ""},
+		{"http://localhost:8080/", ""},
+	}
+	proxy := proxyFunc(network)
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxyURL, err := proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if proxyURL != nil {
+			got = proxyURL.String()
+		}
+		if got != test.want {
+			t.Errorf("%s: got proxy %q, want %q", test.url, got, test.want)
+		}
+	}
+
+	// the env variables are used when the config doesn't set a proxy
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:8080")
+	req, _ := http.NewRequest(http.MethodGet, "https://go.dev/dl/", nil)
+	if proxyURL, err := proxyFunc(configs.NetworkConfig{})(req); err != nil || proxyURL == nil || proxyURL.Host != "env-proxy:8080" {
+		t.Errorf("got %v, %v, want the proxy of HTTPS_PROXY", proxyURL, err)
+	}
+}
+
+func TestHTTPClientCABundles(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	bundle := writeTestFile(t, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+
+	client, err := NewHTTPClient(configs.NetworkConfig{Netrc: "off"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("trusted the test certificate without the CA bundle")
+	}
+
+	client, err = NewHTTPClient(configs.NetworkConfig{Netrc: "off", CABundles: []string{bundle}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, client, server.URL); got != "ok" {
+		t.Fatalf("got %q", got)
+	}
+
+	for _, invalid := range []string{writeTestFile(t, "empty.pem", "not a certificate"), filepath.Join(t.TempDir(), "missing.pem")} {
+		if _, err := NewHTTPClient(configs.NetworkConfig{Netrc: "off", CABundles: []string{invalid}}); err == nil {
+			t.Errorf("%s was accepted as a CA bundle", filepath.Base(invalid))
+		}
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]netrcEntry
+	}{
+		{"machines", "machine a.corp login alice password one\nmachine b.corp\n  login bob\n  password two\n",
+			map[string]netrcEntry{"a.corp": {"alice", "one"}, "b.corp": {"bob", "two"}}},
+		{"default", "machine a.corp login alice password one\ndefault login anon password guest\n",
+			map[string]netrcEntry{"a.corp": {"alice", "one"}, "": {"anon", "guest"}}},
+		{"first entry wins", "machine a.corp login alice password one\nmachine a.corp login eve password two\n",
+			map[string]netrcEntry{"a.corp": {"alice", "one"}}},
+		{"comments and accounts", "# corp\nmachine a.corp login alice account ops password one # inline\n",
+			map[string]netrcEntry{"a.corp": {"alice", "one"}}},
+		{"macdef", "macdef init\nmachine evil login x password y\n\nmachine a.corp login alice password one\n",
+			map[string]netrcEntry{"a.corp": {"alice", "one"}}},
+	}
+	for _, test := range tests {
+		entries, err := parseNetrc(writeTestFile(t, "netrc", test.content))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !maps.Equal(entries, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, entries, test.want)
+		}
+	}
+
+	entries, err := parseNetrc(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("got %v, %v for a missing file, want no entry", entries, err)
+	}
+}
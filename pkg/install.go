@@ -0,0 +1,342 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// == In this file, we stage installs and commit them atomically. == //
+// An archive is extracted into a staging directory next to the install root, validated, then renamed into
+// <root>/<candidate>/<version>. A journal file records every install in progress, so that an install
+// interrupted by Ctrl+C, a crash or a power loss is cleaned up (or completed) on the next run.
+
+const (
+	// journalStaging means the archive is being extracted, the staging directory can be dropped
+	journalStaging = "staging"
+	// journalCommitted means the version directory is complete but the version is not recorded yet
+	journalCommitted = "committed"
+)
+
+type installJournal struct {
+	Candidate  string `json:"candidate"`
+	Version    string `json:"version"`
+	StagingDir string `json:"staging_dir"`
+	Dest       string `json:"dest"`
+	// Current is set when the version is the default one, it is then committed into <candidate>/current
+	Current bool `json:"current,omitempty"`
+	// Previous is where the replaced install of the same version is kept until the commit is done
+	Previous  string    `json:"previous,omitempty"`
+	Home      string    `json:"home"`
+	State     string    `json:"state"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+	// Record is what gets recorded in deto.json once the version is in place
+	Record Installation `json:"record"`
+	path   string
+}
+
+func getJournalDir() (string, error) {
+	return rootPath(DefaultJournalLocation)
+}
+
+func getStagingDir() (string, error) {
+	return rootPath(DefaultStagingLocation)
+}
+
+// beginInstall creates the staging directory of a version and records the install in the journal
+func beginInstall(candidate string, record Installation) (*installJournal, error) {
+	version := record.Version
+	root, err := RootDir()
+	if err != nil {
+		return nil, err
+	}
+	journalDir := filepath.Join(root, DefaultJournalLocation)
+	if err := os.MkdirAll(journalDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(root, DefaultStagingLocation), 0755); err != nil {
+		return nil, err
+	}
+
+	stagingDir, err := os.MkdirTemp(filepath.Join(root, DefaultStagingLocation), fmt.Sprintf("%s-%s-", candidate, version))
+	if err != nil {
+		return nil, err
+	}
+
+	// a reinstall of the default version replaces the tree of current, so it stays the default one
+	current, err := isCurrentVersion(candidate, version)
+	if err != nil {
+		_ = os.RemoveAll(stagingDir)
+		return nil, err
+	}
+	dest := filepath.Join(root, candidate, version)
+	if current {
+		dest = filepath.Join(root, filepath.FromSlash(currentPath(candidate)))
+	}
+
+	journal := &installJournal{
+		Candidate:  candidate,
+		Version:    version,
+		StagingDir: stagingDir,
+		Dest:       dest,
+		Current:    current,
+		State:      journalStaging,
+		PID:        os.Getpid(),
+		StartedAt:  time.Now(),
+		Record:     record,
+		path:       filepath.Join(journalDir, filepath.Base(stagingDir)+".json"),
+	}
+	if err := journal.save(); err != nil {
+		_ = os.RemoveAll(stagingDir)
+		return nil, err
+	}
+	return journal, nil
+}
+
+func (j *installJournal) save() error {
+	byteData, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	tmpPath := j.path + ".tmp"
+	if err := os.WriteFile(tmpPath, byteData, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, j.path)
+}
+
+// commit validates the staging directory and renames it into place. An existing install of the same
+// version is replaced and restored if the rename fails. The journal records where the existing install is
+// kept, so that a crash between the renames restores it, see RecoverInterruptedInstalls.
+func (j *installJournal) commit() error {
+	home, err := findHomeDir(j.StagingDir)
+	if err != nil {
+		return err
+	}
+	j.Home = home
+
+	if err := os.MkdirAll(filepath.Dir(j.Dest), 0755); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(j.Dest); err == nil {
+		j.Previous = fmt.Sprintf("%s.old-%d", j.StagingDir, time.Now().UnixNano())
+		if err := j.save(); err != nil {
+			return err
+		}
+		if err := os.Rename(j.Dest, j.Previous); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(j.StagingDir, j.Dest); err != nil {
+		j.restorePrevious()
+		return err
+	}
+
+	j.State = journalCommitted
+	if err := j.save(); err != nil {
+		return err
+	}
+	if j.Previous != "" {
+		_ = os.RemoveAll(j.Previous)
+	}
+	return nil
+}
+
+// restorePrevious puts the replaced install back in place of a commit that didn't complete
+func (j *installJournal) restorePrevious() {
+	if j.Previous == "" {
+		return
+	}
+	if _, err := os.Lstat(j.Previous); err != nil {
+		return
+	}
+	if _, err := os.Stat(j.StagingDir); errors.Is(err, os.ErrNotExist) {
+		// the new tree was renamed into place already
+		_ = os.RemoveAll(j.Dest)
+	}
+	_ = os.Rename(j.Previous, j.Dest)
+}
+
+// isCurrentVersion tells whether the version is the default version of the candidate
+func isCurrentVersion(candidate string, version string) (bool, error) {
+	configs, err := LoadData()
+	if err != nil {
+		return false, err
+	}
+	for _, config := range configs {
+		if config.Candidate == candidate {
+			return config.Current == version, nil
+		}
+	}
+	return false, nil
+}
+
+// rollback restores the replaced install, drops the staging directory and the journal entry
+func (j *installJournal) rollback() {
+	j.restorePrevious()
+	_ = os.RemoveAll(j.StagingDir)
+	_ = os.Remove(j.path)
+}
+
+// installation returns the record of the committed version
+func (j *installJournal) installation() Installation {
+	record := j.Record
+	record.Version = j.Version
+	record.Path = installPath(j.Candidate, j.Version)
+	if j.Current {
+		record.Path = currentPath(j.Candidate)
+	}
+	record.Home = j.Home
+	if record.InstalledAt.IsZero() {
+		record.InstalledAt = time.Now()
+	}
+	return record
+}
+
+// finish removes the journal entry and the replaced install once the version is recorded
+func (j *installJournal) finish() {
+	if j.Previous != "" {
+		_ = os.RemoveAll(j.Previous)
+	}
+	_ = os.Remove(j.path)
+}
+
+// findHomeDir makes sure the extraction produced an install and returns the home directory of the install
+// relative to dir, that is the shallowest directory containing a bin directory. It is "." once the layout is
+// normalized, see layout.go.
+func findHomeDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", errors.New("the archive is empty")
+	}
+
+	const maxDepth = 4
+	level := []string{"."}
+	for depth := 0; depth <= maxDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, rel := range level {
+			entries, err := os.ReadDir(filepath.Join(dir, rel))
+			if err != nil {
+				return "", err
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					continue
+				}
+				if entry.Name() == "bin" {
+					return rel, nil
+				}
+				next = append(next, filepath.Join(rel, entry.Name()))
+			}
+		}
+		level = next
+	}
+	return "", errors.New("no bin directory found in the archive")
+}
+
+// RecoverInterruptedInstalls cleans up the installs that were interrupted. Staged installs are rolled back,
+// committed ones are recorded in deto.json. Installs of running deto processes are left alone.
+func RecoverInterruptedInstalls() {
+	journalDir, err := getJournalDir()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(journalDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(journalDir, entry.Name())
+		fileBytes, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		journal := &installJournal{path: path}
+		if err := json.Unmarshal(fileBytes, journal); err != nil {
+			_ = os.Remove(path)
+			continue
+		}
+		if journal.PID != os.Getpid() && processExists(journal.PID) {
+			continue
+		}
+
+		switch journal.State {
+		case journalCommitted:
+			if _, err := os.Stat(journal.Dest); err == nil && !isVersionRecorded(journal.Candidate, journal.Version) {
+				if err := RecordInstall(journal.Candidate, journal.installation()); err != nil {
+					// keep the journal entry, the next run tries again
+					fmt.Printf("Error recording the install of %s %s: %s\n", journal.Candidate, journal.Version, err)
+					continue
+				}
+				fmt.Printf("Completed the interrupted install of %s %s\n", journal.Candidate, journal.Version)
+			}
+			journal.finish()
+		default:
+			journal.rollback()
+			fmt.Printf("Cleaned up the interrupted install of %s %s\n", journal.Candidate, journal.Version)
+		}
+	}
+}
+
+func isVersionRecorded(candidate string, version string) bool {
+	configs, err := LoadData()
+	if err != nil {
+		return false
+	}
+	for _, config := range configs {
+		if config.Candidate == candidate && slices.Contains(config.Versions(), version) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeInstallation deletes the directory of a version and forgets it. The directory is first moved to the
+// staging directory, so that the version is either in place and recorded or gone. Versions deto didn't install
+// are only forgotten.
+func removeInstallation(candidate string, installation Installation, current bool) error {
+	if installation.Root != "" || installation.ExternallyManaged {
+		if current {
+			// drops the link to a shared version
+			if err := releaseCurrent(candidate); err != nil {
+				return err
+			}
+		}
+		return ForgetInstall(candidate, installation.Version)
+	}
+
+	stagingDir, err := getStagingDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return err
+	}
+	dir, err := installationDir(installation)
+	if err != nil {
+		return err
+	}
+	removed := fmt.Sprintf("%s.removed-%d", filepath.Join(stagingDir, candidate+"-"+installation.Version), time.Now().UnixNano())
+	if err := os.Rename(dir, removed); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := ForgetInstall(candidate, installation.Version); err != nil {
+		_ = os.Rename(removed, dir)
+		return err
+	}
+	return os.RemoveAll(removed)
+}
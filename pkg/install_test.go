@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// installTestVersion installs a version whose bin/tool file holds content
+func installTestVersion(t *testing.T, candidate string, version string, content string) {
+	t.Helper()
+	err := installWith(candidate, Installation{Version: version}, func(stagingDir string) error {
+		if err := os.MkdirAll(filepath.Join(stagingDir, "bin"), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(stagingDir, "bin", "tool"), []byte(content), 0755)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReinstallCurrentVersion(t *testing.T) {
+	DetoHome = t.TempDir()
+	defer func() { DetoHome = "" }()
+
+	man := &Man{Candidate: "go"}
+	installTestVersion(t, "go", "1.0", "old")
+	installTestVersion(t, "go", "2.0", "two")
+	man.makeDefaultVersion("1.0")
+
+	installTestVersion(t, "go", "1.0", "new")
+	got, err := os.ReadFile(filepath.Join(DetoHome, "go", "current", "bin", "tool"))
+	if err != nil || string(got) != "new" {
+		t.Fatalf("got %q, %v in go/current, want the reinstalled tree", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(DetoHome, "go", "1.0")); !os.IsNotExist(err) {
+		t.Fatalf("go/1.0 exists next to go/current: %v", err)
+	}
+	installation, _, err := findInstallation("go", "1.0")
+	if err != nil || installation.Path != currentPath("go") {
+		t.Fatalf("got path %q, %v, want %q", installation.Path, err, currentPath("go"))
+	}
+
+	// the next default switch moves the reinstalled tree back to go/1.0
+	man.makeDefaultVersion("2.0")
+	got, err = os.ReadFile(filepath.Join(DetoHome, "go", "1.0", "bin", "tool"))
+	if err != nil || string(got) != "new" {
+		t.Fatalf("got %q, %v in go/1.0 after the switch", got, err)
+	}
+	man.removeVersion("2.0")
+	if _, err := os.Lstat(filepath.Join(DetoHome, "go", "current")); !os.IsNotExist(err) {
+		t.Fatalf("go/current is left behind: %v", err)
+	}
+}
+
+func TestRecoverInterruptedReinstall(t *testing.T) {
+	// a reinstall of go 1.0 crashed between moving the installed tree away and moving the new one in
+	crashes := []struct {
+		name  string
+		crash func(t *testing.T, journal *installJournal)
+	}{
+		{"installed tree moved away", func(t *testing.T, journal *installJournal) {
+			if err := os.Rename(journal.Dest, journal.Previous); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"new tree moved in", func(t *testing.T, journal *installJournal) {
+			if err := os.Rename(journal.Dest, journal.Previous); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(journal.StagingDir, journal.Dest); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range crashes {
+		t.Run(test.name, func(t *testing.T) {
+			DetoHome = t.TempDir()
+			defer func() { DetoHome = "" }()
+			installTestVersion(t, "go", "1.0", "old")
+
+			journal, err := beginInstall("go", Installation{Version: "1.0"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(filepath.Join(journal.StagingDir, "bin"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(journal.StagingDir, "bin", "tool"), []byte("new"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			journal.Previous = journal.StagingDir + ".old-1"
+			if err := journal.save(); err != nil {
+				t.Fatal(err)
+			}
+			test.crash(t, journal)
+
+			RecoverInterruptedInstalls()
+			got, err := os.ReadFile(filepath.Join(DetoHome, "go", "1.0", "bin", "tool"))
+			if err != nil || string(got) != "old" {
+				t.Fatalf("got %q, %v in go/1.0, want the installed tree back", got, err)
+			}
+			for _, path := range []string{journal.Previous, journal.StagingDir, journal.path} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s is left behind: %v", path, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// == In this file, we manage the keyring of keys trusted to sign artifacts. == //
+// The keyring is the keys directory of the install root. OpenPGP keys are stored as <FINGERPRINT>.asc and minisign keys as
+// <KEYID>.pub, so a registry entry finds its key from the fingerprint it references.
+
+const (
+	SignatureTypePGP      = "pgp"
+	SignatureTypeMinisign = "minisign"
+
+	pgpKeyExt      = ".asc"
+	minisignKeyExt = ".pub"
+	maxKeySize     = 1 << 20
+)
+
+// TrustedKey is a key of the keyring
+type TrustedKey struct {
+	Fingerprint string
+	Type        string
+	Identity    string
+}
+
+func getKeysDir() (string, error) {
+	return rootPath(DefaultKeysLocation)
+}
+
+// normalizeFingerprint makes fingerprints comparable, e.g. "3b04 d753 ..." or "0x3B04D753..."
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+	return strings.TrimPrefix(fingerprint, "0X")
+}
+
+// checkFingerprint normalizes a fingerprint and makes sure it is one of a key of the type: 40 or 64 hex digits
+// for OpenPGP v4 and v6 keys, 16 for minisign key ids. Fingerprints come from registries and name the files of
+// the keyring, nothing else gets through.
+func checkFingerprint(fingerprint string, keyType string) (string, error) {
+	fingerprint = normalizeFingerprint(fingerprint)
+	lengths := []int{40, 64}
+	if keyType == SignatureTypeMinisign {
+		lengths = []int{16}
+	}
+	if _, err := hex.DecodeString(fingerprint); err != nil || !slices.Contains(lengths, len(fingerprint)) {
+		return "", fmt.Errorf("invalid %s key fingerprint %q", keyType, fingerprint)
+	}
+	return fingerprint, nil
+}
+
+func pgpFingerprint(entity *openpgp.Entity) string {
+	return strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))
+}
+
+func pgpIdentity(entity *openpgp.Entity) string {
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		return identity.Name
+	}
+	return ""
+}
+
+// readPGPKeys reads armored or binary OpenPGP keys
+func readPGPKeys(data []byte) (openpgp.EntityList, error) {
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// ImportKey adds the public keys of a minisign or OpenPGP key file to the keyring
+func ImportKey(path string) ([]TrustedKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxKeySize))
+	if err != nil {
+		return nil, err
+	}
+
+	keysDir, err := getKeysDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(keysDir, 0755); err != nil {
+		return nil, err
+	}
+
+	if minisignKey, err := parseMinisignPublicKey(data); err == nil {
+		fingerprint := minisignKeyID(minisignKey.KeyID)
+		if err := os.WriteFile(filepath.Join(keysDir, fingerprint+minisignKeyExt), data, 0644); err != nil {
+			return nil, err
+		}
+		return []TrustedKey{{Fingerprint: fingerprint, Type: SignatureTypeMinisign, Identity: minisignKey.Comment}}, nil
+	}
+
+	entities, err := readPGPKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a minisign nor an OpenPGP public key: %w", path, err)
+	}
+	var imported []TrustedKey
+	for _, entity := range entities {
+		// only the public part is kept, even when a private key was given
+		var buf bytes.Buffer
+		writer, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return imported, err
+		}
+		if err := entity.Serialize(writer); err != nil {
+			return imported, err
+		}
+		if err := writer.Close(); err != nil {
+			return imported, err
+		}
+
+		fingerprint := pgpFingerprint(entity)
+		if err := os.WriteFile(filepath.Join(keysDir, fingerprint+pgpKeyExt), buf.Bytes(), 0644); err != nil {
+			return imported, err
+		}
+		imported = append(imported, TrustedKey{Fingerprint: fingerprint, Type: SignatureTypePGP, Identity: pgpIdentity(entity)})
+	}
+	return imported, nil
+}
+
+// ListKeys returns the keys of the keyring sorted by fingerprint
+func ListKeys() ([]TrustedKey, error) {
+	keysDir, err := getKeysDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(keysDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []TrustedKey
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(keysDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		switch filepath.Ext(entry.Name()) {
+		case minisignKeyExt:
+			minisignKey, err := parseMinisignPublicKey(data)
+			if err != nil {
+				continue
+			}
+			keys = append(keys, TrustedKey{Fingerprint: minisignKeyID(minisignKey.KeyID), Type: SignatureTypeMinisign, Identity: minisignKey.Comment})
+		case pgpKeyExt:
+			entities, err := readPGPKeys(data)
+			if err != nil {
+				continue
+			}
+			for _, entity := range entities {
+				keys = append(keys, TrustedKey{Fingerprint: pgpFingerprint(entity), Type: SignatureTypePGP, Identity: pgpIdentity(entity)})
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Fingerprint < keys[j].Fingerprint })
+	return keys, nil
+}
+
+// RemoveKey removes a key from the keyring
+func RemoveKey(fingerprint string) error {
+	keysDir, err := getKeysDir()
+	if err != nil {
+		return err
+	}
+	for keyType, ext := range map[string]string{SignatureTypePGP: pgpKeyExt, SignatureTypeMinisign: minisignKeyExt} {
+		keyFingerprint, err := checkFingerprint(fingerprint, keyType)
+		if err != nil {
+			continue
+		}
+		err = os.Remove(filepath.Join(keysDir, keyFingerprint+ext))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return fmt.Errorf("no trusted key with fingerprint %s", normalizeFingerprint(fingerprint))
+}
+
+// loadPGPKeyring returns the trusted OpenPGP key with the fingerprint, or every trusted OpenPGP key when the
+// fingerprint is empty
+func loadPGPKeyring(fingerprint string) (openpgp.EntityList, error) {
+	keysDir, err := getKeysDir()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	if fingerprint != "" {
+		fingerprint, err := checkFingerprint(fingerprint, SignatureTypePGP)
+		if err != nil {
+			return nil, err
+		}
+		paths = []string{filepath.Join(keysDir, fingerprint+pgpKeyExt)}
+	} else {
+		paths, _ = filepath.Glob(filepath.Join(keysDir, "*"+pgpKeyExt))
+	}
+
+	var keyring openpgp.EntityList
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entities, err := readPGPKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", path, err)
+		}
+		keyring = append(keyring, entities...)
+	}
+	return keyring, nil
+}
+
+// loadMinisignKey returns the trusted minisign key with the key id
+func loadMinisignKey(keyID string) (*minisignPublicKey, error) {
+	keysDir, err := getKeysDir()
+	if err != nil {
+		return nil, err
+	}
+	keyID, err = checkFingerprint(keyID, SignatureTypeMinisign)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(keysDir, keyID+minisignKeyExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseMinisignPublicKey(data)
+}
@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// == In this file, we normalize the layout of an extracted archive. == //
+// Archives wrap their content differently: go/..., jdk-21.0.4+7/..., jdk-21.0.4+7/Contents/Home/... on macOS.
+// We strip those wrappers so that every install has <root>/<candidate>/<version>/bin.
+
+// ignoredLayoutEntries are metadata entries of archives made on macOS, they don't count as content
+var ignoredLayoutEntries = []string{"__MACOSX", ".DS_Store"}
+
+// normalizeLayout hoists the content of a single top-level directory and of a macOS bundle (Contents/Home)
+// into dir, until dir has a bin directory or no wrapper is left
+func normalizeLayout(dir string) error {
+	for {
+		if info, err := os.Stat(filepath.Join(dir, "bin")); err == nil && info.IsDir() {
+			return nil
+		}
+
+		wrapper, err := layoutWrapper(dir)
+		if err != nil || wrapper == "" {
+			return err
+		}
+		if err := hoistDir(dir, wrapper); err != nil {
+			return err
+		}
+	}
+}
+
+// layoutWrapper returns the directory whose content should replace the content of dir, or "" if there is none
+func layoutWrapper(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var content []os.DirEntry
+	for _, entry := range entries {
+		if isIgnoredLayoutEntry(entry.Name()) {
+			continue
+		}
+		content = append(content, entry)
+	}
+	if len(content) != 1 || !content[0].IsDir() {
+		return "", nil
+	}
+
+	// a macOS bundle keeps its home under Contents/Home
+	if content[0].Name() == "Contents" {
+		bundleHome := filepath.Join("Contents", "Home")
+		if info, err := os.Stat(filepath.Join(dir, bundleHome)); err == nil && info.IsDir() {
+			return bundleHome, nil
+		}
+	}
+	return content[0].Name(), nil
+}
+
+func isIgnoredLayoutEntry(name string) bool {
+	for _, ignored := range ignoredLayoutEntries {
+		if name == ignored || strings.HasPrefix(name, "._") {
+			return true
+		}
+	}
+	return false
+}
+
+// hoistDir moves the content of dir/wrapper into dir and removes everything else at the top of dir.
+// The wrapper is moved aside first, so that a child with the same name as the wrapper (e.g. go/go) works.
+func hoistDir(dir string, wrapper string) error {
+	aside := filepath.Join(dir, fmt.Sprintf(".deto-hoist-%d", time.Now().UnixNano()))
+	if err := os.Rename(filepath.Join(dir, wrapper), aside); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if path == aside {
+			continue
+		}
+		// the rest of the bundle (Info.plist, MacOS/...) and metadata entries
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	children, err := os.ReadDir(aside)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := os.Rename(filepath.Join(aside, child.Name()), filepath.Join(dir, child.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(aside)
+}
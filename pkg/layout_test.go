@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTestTree creates the files of paths under dir, names ending with / are directories
+func writeTestTree(t *testing.T, dir string, paths []string) {
+	t.Helper()
+	for _, path := range paths {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if path[len(path)-1] == '/' {
+			if err := os.MkdirAll(full, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listTestTree returns the files under dir, relative to dir
+func listTestTree(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestNormalizeLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "already normalized",
+			files: []string{"bin/go", "VERSION"},
+			want:  []string{"VERSION", "bin/go"},
+		},
+		{
+			name:  "single top-level directory",
+			files: []string{"jdk-21.0.4+7/bin/java", "jdk-21.0.4+7/release"},
+			want:  []string{"bin/java", "release"},
+		},
+		{
+			name:  "child with the name of the wrapper",
+			files: []string{"go/bin/go", "go/go/README"},
+			want:  []string{"bin/go", "go/README"},
+		},
+		{
+			name:  "macOS bundle",
+			files: []string{"jdk-21.0.4+7/Contents/Home/bin/java", "jdk-21.0.4+7/Contents/Info.plist", "jdk-21.0.4+7/Contents/MacOS/libjli.dylib"},
+			want:  []string{"bin/java"},
+		},
+		{
+			name:  "macOS metadata entries",
+			files: []string{"__MACOSX/._jdk", ".DS_Store", "._jdk", "jdk/bin/java"},
+			want:  []string{"bin/java"},
+		},
+		{
+			name:  "several top-level entries",
+			files: []string{"jdk/bin/java", "LICENSE"},
+			want:  []string{"LICENSE", "jdk/bin/java"},
+		},
+		{
+			name:  "nested wrappers without bin",
+			files: []string{"node-v20/node-v20/lib/node", "node-v20/node-v20/share/"},
+			want:  []string{"lib/node"},
+		},
+		{
+			name:  "single file",
+			files: []string{"tool"},
+			want:  []string{"tool"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestTree(t, dir, test.files)
+			if err := normalizeLayout(dir); err != nil {
+				t.Fatal(err)
+			}
+			if got := listTestTree(t, dir); !slices.Equal(got, test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
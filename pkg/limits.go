@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// == In this file, we bound what an archive may write while it is extracted. == //
+// Archives can come from private registries with third-party artifacts, so the extractor treats them as
+// hostile: every entry counts against the limits below and any rejected entry fails the whole install with an
+// error naming the entry.
+
+// ExtractLimits are the limits of a single extraction, a zero value disables the limit
+type ExtractLimits struct {
+	// MaxTotalBytes is the total size of the extracted files
+	MaxTotalBytes int64
+	// MaxFiles is the number of entries, directories and links included
+	MaxFiles int
+	// MaxFileBytes is the size of a single file
+	MaxFileBytes int64
+	// MaxPathDepth is the number of path elements of an entry name
+	MaxPathDepth int
+}
+
+// DefaultExtractLimits fit the largest JDK images with plenty of room
+var DefaultExtractLimits = ExtractLimits{
+	MaxTotalBytes: 16 << 30,
+	MaxFiles:      200_000,
+	MaxFileBytes:  4 << 30,
+	MaxPathDepth:  64,
+}
+
+// errUnsafeEntry is wrapped by the errors of rejected archive entries
+var errUnsafeEntry = errors.New("unsafe archive entry")
+
+// entryError names the archive entry that was rejected and why
+type entryError struct {
+	Name   string
+	Reason string
+}
+
+func (e *entryError) Error() string {
+	return fmt.Sprintf("archive entry %q rejected: %s", e.Name, e.Reason)
+}
+
+func (e *entryError) Unwrap() error {
+	return errUnsafeEntry
+}
+
+// extractBudget counts the entries and bytes of an extraction against the limits
+type extractBudget struct {
+	limits ExtractLimits
+	files  int
+	bytes  int64
+}
+
+func newExtractBudget() *extractBudget {
+	return &extractBudget{limits: DefaultExtractLimits}
+}
+
+// addEntry counts an entry and checks the depth of its name. size is the size declared by the archive.
+func (b *extractBudget) addEntry(name string, size int64) error {
+	b.files++
+	if b.limits.MaxFiles > 0 && b.files > b.limits.MaxFiles {
+		return &entryError{Name: name, Reason: fmt.Sprintf("the archive has more than %d entries", b.limits.MaxFiles)}
+	}
+
+	depth := 0
+	for _, part := range strings.Split(strings.ReplaceAll(name, "\\", "/"), "/") {
+		if part != "" && part != "." {
+			depth++
+		}
+	}
+	if b.limits.MaxPathDepth > 0 && depth > b.limits.MaxPathDepth {
+		return &entryError{Name: name, Reason: fmt.Sprintf("the path is deeper than %d levels", b.limits.MaxPathDepth)}
+	}
+
+	// the declared size may lie, the reader checks the actual bytes
+	if b.limits.MaxFileBytes > 0 && size > b.limits.MaxFileBytes {
+		return &entryError{Name: name, Reason: fmt.Sprintf("the file is larger than %s", FormatBytes(b.limits.MaxFileBytes))}
+	}
+	if b.limits.MaxTotalBytes > 0 && b.bytes+size > b.limits.MaxTotalBytes {
+		return &entryError{Name: name, Reason: fmt.Sprintf("the archive expands to more than %s", FormatBytes(b.limits.MaxTotalBytes))}
+	}
+	return nil
+}
+
+// reader wraps the content of the entry name and fails once it goes over the limits
+func (b *extractBudget) reader(name string, reader io.Reader) io.Reader {
+	return &budgetReader{reader: reader, budget: b, name: name}
+}
+
+type budgetReader struct {
+	reader  io.Reader
+	budget  *extractBudget
+	name    string
+	written int64
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.written += int64(n)
+	r.budget.bytes += int64(n)
+
+	limits := r.budget.limits
+	if limits.MaxFileBytes > 0 && r.written > limits.MaxFileBytes {
+		return n, &entryError{Name: r.name, Reason: fmt.Sprintf("the file is larger than %s", FormatBytes(limits.MaxFileBytes))}
+	}
+	if limits.MaxTotalBytes > 0 && r.budget.bytes > limits.MaxTotalBytes {
+		return n, &entryError{Name: r.name, Reason: fmt.Sprintf("the archive expands to more than %s", FormatBytes(limits.MaxTotalBytes))}
+	}
+	return n, err
+}
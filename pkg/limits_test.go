@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExtractLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  ExtractLimits
+		files   map[string]int
+		wantErr string
+	}{
+		{"within the limits", ExtractLimits{MaxTotalBytes: 100, MaxFiles: 2, MaxFileBytes: 50, MaxPathDepth: 3}, map[string]int{"a/b/c": 50, "d": 50}, ""},
+		{"too many files", ExtractLimits{MaxFiles: 2}, map[string]int{"a": 1, "b": 1, "c": 1}, "more than 2 entries"},
+		{"file too large", ExtractLimits{MaxFileBytes: 10}, map[string]int{"a": 11}, "larger than"},
+		{"archive too large", ExtractLimits{MaxTotalBytes: 10}, map[string]int{"a": 6, "b": 6}, "expands to more than"},
+		{"path too deep", ExtractLimits{MaxPathDepth: 2}, map[string]int{"a/b/c": 1}, "deeper than 2 levels"},
+		{"dots don't count", ExtractLimits{MaxPathDepth: 2}, map[string]int{"./a/./b": 1}, ""},
+		{"no limits", ExtractLimits{}, map[string]int{"a/b/c/d/e": 1 << 16}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defaults := DefaultExtractLimits
+			DefaultExtractLimits = test.limits
+			defer func() { DefaultExtractLimits = defaults }()
+
+			var buf bytes.Buffer
+			writer := tar.NewWriter(&buf)
+			for name, size := range test.files {
+				if err := writer.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(size)}); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := writer.Write(make([]byte, size)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := extractTar(context.Background(), tar.NewReader(&buf), t.TempDir())
+			if test.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.wantErr != "" && (!errors.Is(err, errUnsafeEntry) || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("got %v, want an unsafe entry error with %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractBudgetCountsActualBytes(t *testing.T) {
+	// a zip entry can declare less than it holds, the reader stops at the limit
+	budget := &extractBudget{limits: ExtractLimits{MaxFileBytes: 10, MaxTotalBytes: 15}}
+	if err := budget.addEntry("a", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, budget.reader("a", bytes.NewReader(make([]byte, 11)))); !errors.Is(err, errUnsafeEntry) {
+		t.Fatalf("got %v for a file over the size limit", err)
+	}
+
+	budget = &extractBudget{limits: ExtractLimits{MaxFileBytes: 10, MaxTotalBytes: 15}}
+	for _, name := range []string{"a", "b"} {
+		if err := budget.addEntry(name, 0); err != nil {
+			t.Fatal(err)
+		}
+		_, err := io.Copy(io.Discard, budget.reader(name, bytes.NewReader(make([]byte, 8))))
+		if name == "a" && err != nil || name == "b" && !errors.Is(err, errUnsafeEntry) {
+			t.Fatalf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestExtractRejectsUnsafeEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarTestEntry
+	}{
+		{"dot dot", []tarTestEntry{{name: "../evil", typeflag: tar.TypeReg}}},
+		{"absolute path", []tarTestEntry{{name: "/tmp/evil", typeflag: tar.TypeReg}}},
+		{"absolute symlink", []tarTestEntry{{name: "etc", typeflag: tar.TypeSymlink, linkname: "/etc"}}},
+		{"hard link outside", []tarTestEntry{{name: "passwd", typeflag: tar.TypeLink, linkname: "../etc/passwd"}}},
+		{"device", []tarTestEntry{{name: "null", typeflag: tar.TypeChar}}},
+		{"fifo", []tarTestEntry{{name: "fifo", typeflag: tar.TypeFifo}}},
+	}
+	for _, test := range tests {
+		err := extractTestTar(t, test.entries)
+		var entryErr *entryError
+		if !errors.As(err, &entryErr) || entryErr.Name != test.entries[0].name {
+			t.Errorf("%s: got %v, want an error naming %s", test.name, err, test.entries[0].name)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package pkg
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an advisory lock on path, waiting for other processes to release it
+func lockFile(path string, exclusive bool) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := unix.Flock_t{Type: unix.F_RDLCK}
+	if exclusive {
+		lock.Type = unix.F_WRLCK
+	}
+	for {
+		err = unix.FcntlFlock(file.Fd(), unix.F_SETLKW, &lock)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return func() {
+		lock.Type = unix.F_UNLCK
+		_ = unix.FcntlFlock(file.Fd(), unix.F_SETLK, &lock)
+		file.Close()
+	}, nil
+}
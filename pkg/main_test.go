@@ -0,0 +1,14 @@
+package pkg
+
+import (
+	"os"
+	"testing"
+
+	"github.com/halng/deto/tui"
+)
+
+func TestMain(m *testing.M) {
+	// spinners and progress bars neither read nor draw on the terminal during the tests
+	tui.Plain = true
+	os.Exit(m.Run())
+}
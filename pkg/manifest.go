@@ -0,0 +1,259 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// == In this file, we record and check the integrity of installed trees. == //
+// Every install writes a manifest of its files with their size, mode and SHA-256 at the root of the version
+// directory. Verifying an install hashes the tree again and reports the files that were modified, removed or
+// added since, e.g. a patched cacerts.
+
+const ManifestFileName = ".deto-manifest.json"
+
+const (
+	manifestFile    = "file"
+	manifestDir     = "dir"
+	manifestSymlink = "symlink"
+)
+
+type Manifest struct {
+	Candidate string          `json:"candidate"`
+	Version   string          `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is a file of the tree, Path is slash separated and relative to the version directory
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Mode   string `json:"mode"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// ManifestChange is a difference between a tree and its manifest
+type ManifestChange struct {
+	Path   string
+	Reason string
+}
+
+// VerifyReport lists the differences found by VerifyInstall
+type VerifyReport struct {
+	Candidate string
+	Version   string
+	Modified  []ManifestChange
+	Missing   []string
+	Extra     []string
+}
+
+func (r *VerifyReport) OK() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// scanTree returns the entries of every file under dir except the manifest itself. Files are hashed only when
+// hash returns true for them.
+func scanTree(dir string, hash func(entry ManifestEntry) bool) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." || rel == ManifestFileName {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entry := ManifestEntry{Path: filepath.ToSlash(rel), Mode: fmt.Sprintf("%04o", info.Mode().Perm())}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			entry.Type = manifestSymlink
+			if entry.Target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case info.IsDir():
+			entry.Type = manifestDir
+		default:
+			entry.Type = manifestFile
+			entry.Size = info.Size()
+			if hash(entry) {
+				if entry.SHA256, err = hashFile(path); err != nil {
+					return err
+				}
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// writeManifest records the tree of an install at its root
+func writeManifest(dir string, candidate string, version string) (*Manifest, error) {
+	files, err := scanTree(dir, func(ManifestEntry) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Candidate: candidate, Version: version, CreatedAt: time.Now(), Files: files}
+	byteData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return manifest, os.WriteFile(filepath.Join(dir, ManifestFileName), byteData, 0644)
+}
+
+// Size returns the total size of the files of the tree
+func (m *Manifest) Size() int64 {
+	var size int64
+	for _, entry := range m.Files {
+		size += entry.Size
+	}
+	return size
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	fileBytes, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(fileBytes, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// VersionDir returns the directory of an installed version, as recorded in deto.json or in a shared root
+func VersionDir(candidate string, version string) (string, error) {
+	configs, err := LoadData()
+	if err != nil {
+		return "", err
+	}
+	for _, config := range configs {
+		if config.Candidate != candidate {
+			continue
+		}
+		if installation, ok := config.Find(version); ok && installation.Path != "" {
+			return installationDir(installation)
+		}
+	}
+	if installation, ok, _ := findSharedInstallation(candidate, version); ok {
+		return installationDir(installation)
+	}
+	return rootPath(candidate, version)
+}
+
+// VerifyInstall hashes the tree of an installed version again and compares it with its manifest
+func VerifyInstall(candidate string, version string) (*VerifyReport, error) {
+	version, err := ResolveVersion(candidate, version)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := VersionDir(candidate, version)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s %s has no manifest, reinstall it to create one", candidate, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[string]ManifestEntry, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		expected[entry.Path] = entry
+	}
+	// files whose size changed are modified anyway, no need to hash them
+	files, err := scanTree(dir, func(entry ManifestEntry) bool {
+		want, ok := expected[entry.Path]
+		return ok && want.Type == manifestFile && want.Size == entry.Size
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{Candidate: candidate, Version: version}
+	actual := make(map[string]ManifestEntry, len(files))
+	for _, entry := range files {
+		actual[entry.Path] = entry
+		want, ok := expected[entry.Path]
+		if !ok {
+			report.Extra = append(report.Extra, entry.Path)
+			continue
+		}
+		if reason := compareEntry(want, entry); reason != "" {
+			report.Modified = append(report.Modified, ManifestChange{Path: entry.Path, Reason: reason})
+		}
+	}
+	for path := range expected {
+		if _, ok := actual[path]; !ok {
+			report.Missing = append(report.Missing, path)
+		}
+	}
+
+	sort.Slice(report.Modified, func(i, j int) bool { return report.Modified[i].Path < report.Modified[j].Path })
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	return report, nil
+}
+
+// compareEntry returns why got differs from want, or "" when it doesn't
+func compareEntry(want ManifestEntry, got ManifestEntry) string {
+	switch {
+	case want.Type != got.Type:
+		return fmt.Sprintf("was a %s, is a %s", want.Type, got.Type)
+	case want.Type == manifestSymlink && want.Target != got.Target:
+		return fmt.Sprintf("link target changed from %s to %s", want.Target, got.Target)
+	case want.Type == manifestFile && want.Size != got.Size:
+		return fmt.Sprintf("size changed from %d to %d", want.Size, got.Size)
+	case want.Type == manifestFile && want.SHA256 != got.SHA256:
+		return "content changed"
+	case runtime.GOOS != "windows" && want.Type != manifestSymlink && modeOf(want) != modeOf(got):
+		// Windows only knows read-only files, modes don't round trip
+		return fmt.Sprintf("mode changed from %s to %s", want.Mode, got.Mode)
+	}
+	return ""
+}
+
+func modeOf(entry ManifestEntry) uint64 {
+	mode, _ := strconv.ParseUint(entry.Mode, 8, 32)
+	return mode
+}
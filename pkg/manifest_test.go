@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestVerifyInstall(t *testing.T) {
+	tests := []struct {
+		name         string
+		tamper       func(t *testing.T, dir string) error
+		wantModified []ManifestChange
+		wantMissing  []string
+		wantExtra    []string
+	}{
+		{"untouched", func(t *testing.T, dir string) error { return nil }, nil, nil, nil},
+		{"content changed", func(t *testing.T, dir string) error {
+			return os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("eno"), 0755)
+		}, []ManifestChange{{Path: "bin/tool", Reason: "content changed"}}, nil, nil},
+		{"size changed", func(t *testing.T, dir string) error {
+			return os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("patched"), 0755)
+		}, []ManifestChange{{Path: "bin/tool", Reason: "size changed from 3 to 7"}}, nil, nil},
+		{"file removed", func(t *testing.T, dir string) error {
+			return os.Remove(filepath.Join(dir, "bin", "tool"))
+		}, nil, []string{"bin/tool"}, nil},
+		{"file added", func(t *testing.T, dir string) error {
+			return os.WriteFile(filepath.Join(dir, "bin", "extra"), nil, 0644)
+		}, nil, nil, []string{"bin/extra"}},
+		{"file replaced by a link", func(t *testing.T, dir string) error {
+			path := filepath.Join(dir, "bin", "tool")
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			return os.Symlink("/bin/sh", path)
+		}, []ManifestChange{{Path: "bin/tool", Reason: "was a file, is a symlink"}}, nil, nil},
+		{"mode changed", func(t *testing.T, dir string) error {
+			return os.Chmod(filepath.Join(dir, "bin", "tool"), 0777)
+		}, []ManifestChange{{Path: "bin/tool", Reason: "mode changed from 0755 to 0777"}}, nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.name == "mode changed" && runtime.GOOS == "windows" {
+				t.Skip("Windows only knows read-only files")
+			}
+			DetoHome = t.TempDir()
+			defer func() { DetoHome = "" }()
+			installTestVersion(t, "go", "1.0", "one")
+
+			dir, err := VersionDir("go", "1.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.tamper(t, dir); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := VerifyInstall("go", "1.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(report.Modified, test.wantModified) || !slices.Equal(report.Missing, test.wantMissing) ||
+				!slices.Equal(report.Extra, test.wantExtra) {
+				t.Fatalf("got modified %v, missing %v, extra %v", report.Modified, report.Missing, report.Extra)
+			}
+			if report.OK() != (test.wantModified == nil && test.wantMissing == nil && test.wantExtra == nil) {
+				t.Fatalf("got OK %v", report.OK())
+			}
+		})
+	}
+}
+
+func TestVerifyInstallWithoutManifest(t *testing.T) {
+	DetoHome = t.TempDir()
+	defer func() { DetoHome = "" }()
+	installTestVersion(t, "go", "1.0", "one")
+
+	dir, err := VersionDir("go", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, ManifestFileName)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyInstall("go", "1.0"); err == nil {
+		t.Fatal("verified an install without a manifest")
+	}
+}
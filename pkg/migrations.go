@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// == In this file, we migrate deto.json files written by older releases. == //
+// Every schema change bumps CurrentSchemaVersion and registers the migration from the previous schema in
+// migrations. A file is upgraded one schema at a time on load and written back in the current schema by the
+// next change. Migrations work on raw JSON so that they don't depend on the current types.
+
+// CurrentSchemaVersion is the schema written by this release
+const CurrentSchemaVersion = 2
+
+// migrations upgrades the state of the schema of the key to the next schema
+var migrations = map[int]func(data []byte) ([]byte, error){
+	1: migrateV1ToV2,
+}
+
+var errNewerSchema = errors.New("deto.json was written by a newer release of deto")
+
+// schemaVersionOf returns the schema of a state file. Schema 1 is the unversioned array of candidates.
+func schemaVersionOf(data []byte) (int, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return 1, nil
+	}
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	if header.SchemaVersion < 1 {
+		return 0, errors.New("missing schema_version")
+	}
+	return header.SchemaVersion, nil
+}
+
+// decodeState migrates a state file to the current schema and parses it. It returns the schema of the file.
+func decodeState(data []byte) (State, int, error) {
+	schemaVersion, err := schemaVersionOf(data)
+	if err != nil {
+		return State{}, 0, err
+	}
+	if schemaVersion > CurrentSchemaVersion {
+		return State{}, 0, fmt.Errorf("%w (schema %d), please upgrade deto", errNewerSchema, schemaVersion)
+	}
+
+	for version := schemaVersion; version < CurrentSchemaVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return State{}, 0, fmt.Errorf("no migration from schema %d", version)
+		}
+		if data, err = migrate(data); err != nil {
+			return State{}, 0, fmt.Errorf("migrating from schema %d: %w", version, err)
+		}
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, 0, err
+	}
+	if state.Candidates == nil {
+		state.Candidates = []Config{}
+	}
+	return state, schemaVersion, nil
+}
+
+// migrateV1ToV2 turns the array of {candidate, versions, current, homes} into installation records. The
+// default version of schema 1 lives in <candidate>/current.
+func migrateV1ToV2(data []byte) ([]byte, error) {
+	var candidates []struct {
+		Candidate string            `json:"candidate"`
+		Versions  []string          `json:"versions"`
+		Current   string            `json:"current"`
+		Homes     map[string]string `json:"homes"`
+	}
+	if err := json.Unmarshal(data, &candidates); err != nil {
+		return nil, err
+	}
+
+	type installationV2 struct {
+		Version string `json:"version"`
+		Path    string `json:"path"`
+		Home    string `json:"home,omitempty"`
+	}
+	type configV2 struct {
+		Candidate     string           `json:"candidate"`
+		Current       string           `json:"current"`
+		Installations []installationV2 `json:"installations"`
+	}
+	state := struct {
+		SchemaVersion int        `json:"schema_version"`
+		Candidates    []configV2 `json:"candidates"`
+	}{SchemaVersion: 2, Candidates: []configV2{}}
+
+	for _, candidate := range candidates {
+		config := configV2{Candidate: candidate.Candidate, Current: candidate.Current, Installations: []installationV2{}}
+		for _, version := range candidate.Versions {
+			path := candidate.Candidate + "/" + version
+			if version == candidate.Current {
+				path = candidate.Candidate + "/current"
+			}
+			config.Installations = append(config.Installations, installationV2{Version: version, Path: path, Home: candidate.Homes[version]})
+		}
+		state.Candidates = append(state.Candidates, config)
+	}
+	return json.Marshal(state)
+}
@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeState(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		schemaVersion int
+		want          []Config
+		err           error
+	}{
+		{
+			name:          "v1 without homes",
+			data:          `[{"candidate":"go","versions":["go1.22","go1.23"],"current":"go1.23"}]`,
+			schemaVersion: 1,
+			want: []Config{{Candidate: "go", Current: "go1.23", Installations: []Installation{
+				{Version: "go1.22", Path: "go/go1.22"},
+				{Version: "go1.23", Path: "go/current"},
+			}}},
+		},
+		{
+			name:          "v1 with homes",
+			data:          `[{"candidate":"java","versions":["21.0.4-tem"],"current":"","homes":{"21.0.4-tem":"jdk-21.0.4+7"}}]`,
+			schemaVersion: 1,
+			want: []Config{{Candidate: "java", Installations: []Installation{
+				{Version: "21.0.4-tem", Path: "java/21.0.4-tem", Home: "jdk-21.0.4+7"},
+			}}},
+		},
+		{
+			name:          "empty v1",
+			data:          `[]`,
+			schemaVersion: 1,
+			want:          []Config{},
+		},
+		{
+			name:          "v2",
+			data:          `{"schema_version":2,"candidates":[{"candidate":"go","current":"go1.23","installations":[{"version":"go1.23","path":"go/current","size":42}]}]}`,
+			schemaVersion: 2,
+			want: []Config{{Candidate: "go", Current: "go1.23", Installations: []Installation{
+				{Version: "go1.23", Path: "go/current", Size: 42},
+			}}},
+		},
+		{
+			name: "newer schema",
+			data: `{"schema_version":99,"candidates":[]}`,
+			err:  errNewerSchema,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, schemaVersion, err := decodeState([]byte(test.data))
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("got %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schemaVersion != test.schemaVersion {
+				t.Errorf("got schema %d, want %d", schemaVersion, test.schemaVersion)
+			}
+			if !reflect.DeepEqual(state.Candidates, test.want) {
+				t.Errorf("got %+v, want %+v", state.Candidates, test.want)
+			}
+		})
+	}
+}
+
+func TestStateStoreRecoversFromBackup(t *testing.T) {
+	dir := t.TempDir()
+	store := &StateStore{path: filepath.Join(dir, DefaultConfigFile)}
+
+	backup := `[{"candidate":"go","versions":["go1.23"],"current":"go1.23"}]`
+	if err := os.WriteFile(store.backupPath(), []byte(backup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.path, []byte(`{"schema_version":2,"candid`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Config{{Candidate: "go", Current: "go1.23", Installations: []Installation{{Version: "go1.23", Path: "go/current"}}}}
+	if !reflect.DeepEqual(configs, want) {
+		t.Fatalf("got %+v, want %+v", configs, want)
+	}
+}
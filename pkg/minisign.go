@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// == In this file, we parse and verify minisign keys and signatures. == //
+// See https://jedisct1.github.io/minisign/ for the format. Signatures are ed25519 over the BLAKE2b-512 hash
+// of the file ("ED"), or over the file itself for legacy signatures ("Ed"). A global signature covers the
+// signature and its trusted comment.
+
+const (
+	minisignUntrustedPrefix = "untrusted comment:"
+	minisignTrustedPrefix   = "trusted comment:"
+)
+
+type minisignPublicKey struct {
+	KeyID   [8]byte
+	Key     ed25519.PublicKey
+	Comment string
+}
+
+type minisignSignature struct {
+	Algorithm       string
+	KeyID           [8]byte
+	Signature       []byte
+	TrustedComment  string
+	GlobalSignature []byte
+}
+
+// minisignKeyID formats a key id the way minisign prints it
+func minisignKeyID(keyID [8]byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(keyID[:]))
+}
+
+// minisignLines returns the non empty lines of a minisign file
+func minisignLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func parseMinisignPublicKey(data []byte) (*minisignPublicKey, error) {
+	lines := minisignLines(data)
+	key := &minisignPublicKey{}
+	if len(lines) > 0 && strings.HasPrefix(lines[0], minisignUntrustedPrefix) {
+		key.Comment = strings.TrimSpace(strings.TrimPrefix(lines[0], minisignUntrustedPrefix))
+		lines = lines[1:]
+	}
+	if len(lines) != 1 {
+		return nil, errors.New("not a minisign public key")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return nil, errors.New("not a minisign public key")
+	}
+	copy(key.KeyID[:], raw[2:10])
+	key.Key = ed25519.PublicKey(raw[10:])
+	return key, nil
+}
+
+func parseMinisignSignature(data []byte) (*minisignSignature, error) {
+	lines := minisignLines(data)
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], minisignUntrustedPrefix) || !strings.HasPrefix(lines[2], minisignTrustedPrefix) {
+		return nil, errors.New("not a minisign signature")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return nil, errors.New("not a minisign signature")
+	}
+	globalSignature, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return nil, errors.New("invalid minisign global signature")
+	}
+
+	sig := &minisignSignature{
+		Algorithm:       string(raw[:2]),
+		Signature:       raw[10:],
+		TrustedComment:  strings.TrimPrefix(lines[2], minisignTrustedPrefix+" "),
+		GlobalSignature: globalSignature,
+	}
+	copy(sig.KeyID[:], raw[2:10])
+	if sig.Algorithm != "ED" && sig.Algorithm != "Ed" {
+		return nil, fmt.Errorf("unsupported minisign algorithm: %s", sig.Algorithm)
+	}
+	return sig, nil
+}
+
+// verify checks the signature of message and its trusted comment
+func (key *minisignPublicKey) verify(message io.Reader, sig *minisignSignature) error {
+	if key.KeyID != sig.KeyID {
+		return fmt.Errorf("signed by key %s, not %s", minisignKeyID(sig.KeyID), minisignKeyID(key.KeyID))
+	}
+
+	var signed []byte
+	if sig.Algorithm == "ED" {
+		hasher, err := blake2b.New512(nil)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(hasher, message); err != nil {
+			return err
+		}
+		signed = hasher.Sum(nil)
+	} else {
+		content, err := io.ReadAll(message)
+		if err != nil {
+			return err
+		}
+		signed = content
+	}
+
+	if !ed25519.Verify(key.Key, signed, sig.Signature) {
+		return errors.New("invalid minisign signature")
+	}
+	global := bytes.Join([][]byte{sig.Signature, []byte(sig.TrustedComment)}, nil)
+	if !ed25519.Verify(key.Key, global, sig.GlobalSignature) {
+		return errors.New("invalid minisign trusted comment")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"strings"
+
+	"github.com/halng/deto/configs"
+)
+
+// mirrorURLs returns the URLs to try for link: the mirrors of the longest matching prefix in order, then link
+// itself unless the rule skips the origin. The checksum from the registry is verified whatever URL served the file.
+func mirrorURLs(link string, mirrors []configs.MirrorConfig) []string {
+	var rule *configs.MirrorConfig
+	for i, mirror := range mirrors {
+		if mirror.Prefix == "" || !strings.HasPrefix(link, mirror.Prefix) {
+			continue
+		}
+		if rule == nil || len(mirror.Prefix) > len(rule.Prefix) {
+			rule = &mirrors[i]
+		}
+	}
+	if rule == nil {
+		return []string{link}
+	}
+
+	var urls []string
+	for _, mirrorURL := range rule.URLs {
+		urls = append(urls, mirrorURL+strings.TrimPrefix(link, rule.Prefix))
+	}
+	if !rule.SkipOrigin || len(urls) == 0 {
+		urls = append(urls, link)
+	}
+	return urls
+}
+
+// linkURLs returns the URLs to try for link using the mirrors of the config file
+func linkURLs(link string) ([]string, error) {
+	mirrors, err := configs.LoadMirrors()
+	if err != nil {
+		return nil, err
+	}
+	return mirrorURLs(link, mirrors), nil
+}
@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/halng/deto/configs"
+	"github.com/spf13/viper"
+)
+
+func TestMirrorURLs(t *testing.T) {
+	mirrors := []configs.MirrorConfig{
+		{Prefix: "https://go.dev/", URLs: []string{"https://artifacts.corp/go/"}},
+		{Prefix: "https://go.dev/dl/", URLs: []string{"https://mirror-a.corp/dl/", "https://mirror-b.corp/dl/"}},
+		{Prefix: "https://github.com/adoptium/", URLs: []string{"https://artifacts.corp/adoptium/"}, SkipOrigin: true},
+		{Prefix: "https://nowhere.corp/", SkipOrigin: true},
+		{Prefix: "", URLs: []string{"https://catch-all.corp/"}},
+	}
+	tests := []struct {
+		link string
+		want []string
+	}{
+		{"https://go.dev/dl/go1.23.2.linux-amd64.tar.gz", []string{
+			"https://mirror-a.corp/dl/go1.23.2.linux-amd64.tar.gz",
+			"https://mirror-b.corp/dl/go1.23.2.linux-amd64.tar.gz",
+			"https://go.dev/dl/go1.23.2.linux-amd64.tar.gz",
+		}},
+		{"https://go.dev/VERSION", []string{"https://artifacts.corp/go/VERSION", "https://go.dev/VERSION"}},
+		{"https://github.com/adoptium/temurin21-binaries/jdk.tar.gz", []string{"https://artifacts.corp/adoptium/temurin21-binaries/jdk.tar.gz"}},
+		// a rule without mirrors can't skip the origin
+		{"https://nowhere.corp/file", []string{"https://nowhere.corp/file"}},
+		{"https://download.java.net/jdk.tar.gz", []string{"https://download.java.net/jdk.tar.gz"}},
+	}
+	for _, test := range tests {
+		if got := mirrorURLs(test.link, mirrors); !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.link, got, test.want)
+		}
+	}
+}
+
+func TestDownloadFromMirrors(t *testing.T) {
+	archive := []byte("go archive")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(archive))
+
+	var mu sync.Mutex
+	var hits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/broken/go.tar.gz":
+			w.Write([]byte("a captive portal page"))
+		case "/missing/go.tar.gz":
+			http.NotFound(w, r)
+		default:
+			w.Write(archive)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		mirror   map[string]any
+		wantErr  bool
+		wantHits []string
+	}{
+		{"mirror", map[string]any{"prefix": server.URL + "/origin/", "urls": []string{server.URL + "/good/"}},
+			false, []string{"/good/go.tar.gz"}},
+		{"broken mirror falls back to the origin", map[string]any{"prefix": server.URL + "/origin/", "urls": []string{server.URL + "/broken/", server.URL + "/missing/"}},
+			false, []string{"/broken/go.tar.gz", "/missing/go.tar.gz", "/origin/go.tar.gz"}},
+		{"skip origin", map[string]any{"prefix": server.URL + "/origin/", "urls": []string{server.URL + "/missing/"}, "skip_origin": true},
+			true, []string{"/missing/go.tar.gz"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a single stream per URL, without the probe of segmented downloads
+			CacheHome, DefaultParallelism = t.TempDir(), 1
+			viper.Set("mirrors", []map[string]any{test.mirror})
+			defer func() {
+				CacheHome, DefaultParallelism = "", 4
+				viper.Set("mirrors", nil)
+			}()
+			hits = nil
+
+			path, err := DownloadAndVerify(server.URL+"/origin/go.tar.gz", checksum, "sha256", "go.tar.gz")
+			if test.wantErr != (err != nil) {
+				t.Fatalf("got %v, want an error: %v", err, test.wantErr)
+			}
+			if err == nil {
+				if got, err := os.ReadFile(path); err != nil || string(got) != string(archive) {
+					t.Fatalf("got %q, %v, want the archive", got, err)
+				}
+			}
+			if !slices.Equal(hits, test.wantHits) {
+				t.Fatalf("got requests %q, want %q", hits, test.wantHits)
+			}
+		})
+	}
+}
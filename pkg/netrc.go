@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// netrcEntry is the login of a machine in a netrc file
+type netrcEntry struct {
+	Login    string
+	Password string
+}
+
+// parseNetrc reads a netrc file and returns the entries by machine name. The "default" entry, if any,
+// is stored under the empty name. A missing file is not an error.
+func parseNetrc(path string) (map[string]netrcEntry, error) {
+	entries := map[string]netrcEntry{}
+	fileBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var machine string
+	var current *netrcEntry
+	save := func() {
+		if current != nil {
+			if _, exists := entries[machine]; !exists {
+				entries[machine] = *current
+			}
+		}
+	}
+
+	lines := strings.Split(string(fileBytes), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			if strings.HasPrefix(fields[j], "#") {
+				break
+			}
+			next := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+				return ""
+			}
+
+			switch fields[j] {
+			case "machine":
+				save()
+				machine = next()
+				current = &netrcEntry{}
+			case "default":
+				save()
+				machine = ""
+				current = &netrcEntry{}
+			case "login":
+				value := next()
+				if current != nil {
+					current.Login = value
+				}
+			case "password":
+				value := next()
+				if current != nil {
+					current.Password = value
+				}
+			case "account":
+				next()
+			case "macdef":
+				// a macro runs until the next empty line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	save()
+	return entries, nil
+}
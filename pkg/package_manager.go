@@ -1,23 +1,29 @@
 package pkg
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"context"
+	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/halng/deto/configs"
 	"github.com/halng/deto/tui"
+	"hash"
 	"io"
-	"log"
+	"maps"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 /*
@@ -44,21 +50,82 @@ THE SOFTWARE.
 
 // == In this package, we will manage the candidate and version that are installed in the system. == //
 
+var errDownloadInterrupted = errors.New("download interrupted, it will be resumed next time")
+
 type Man struct {
 	Candidate       string
 	ActionType      string
 	Architecture    string
 	OperatingSystem string
+	Vendor          string
+	ImageType       string
+	// Stream extracts the archive while it downloads instead of downloading it first
+	Stream bool
+	// Version is the version to install, remove or make the default one. It is asked for when empty.
+	Version string
 }
 
 type RegistryVersion struct {
 	Version      string `json:"version"`
+	FullVersion  string `json:"full_version"`
+	Vendor       string `json:"vendor"`
+	Identifier   string `json:"identifier"`
+	ImageType    string `json:"image_type"`
 	Architecture string `json:"architecture"`
 	Name         string `json:"name"`
 	Checksum     string `json:"checksum"`
+	ChecksumType string `json:"checksum_type"`
 	Provider     string `json:"provider"`
 	IsLTS        bool   `json:"is_lts"`
 	Link         string `json:"link"`
+	// SignatureURL is the detached signature of the file at Link, signed by the key KeyFingerprint
+	SignatureURL   string `json:"signature_url,omitempty"`
+	SignatureType  string `json:"signature_type,omitempty"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+	// Hooks of the publisher, they only run when hooks.allow_registry is set
+	Hooks *Hooks `json:"hooks,omitempty"`
+	// Registry is the url of the registry the entry comes from
+	Registry string `json:"-"`
+}
+
+// newInstallation returns the record of an install of the entry
+func newInstallation(rv RegistryVersion) Installation {
+	return Installation{
+		Version:      rv.InstallKey(),
+		Vendor:       rv.Vendor,
+		Provider:     rv.Provider,
+		SourceURL:    rv.Link,
+		Checksum:     rv.Checksum,
+		ChecksumType: rv.ChecksumType,
+		InstalledAt:  time.Now(),
+		Registry:     rv.Registry,
+		Hooks:        rv.Hooks,
+	}
+}
+
+// InstallKey returns the name used for the install directory and deto.json.
+// Vendor qualified identifiers (e.g. 21.0.4-tem) win over the plain version so that
+// two vendors or two updates of the same major version never collide.
+func (rv RegistryVersion) InstallKey() string {
+	if rv.Identifier != "" {
+		return rv.Identifier
+	}
+	return rv.Version
+}
+
+// DefaultImageType is installed when no image type was requested. Registry entries without
+// an image type (e.g. go) are treated as this image type.
+const DefaultImageType = "jdk"
+
+// osAliases and archAliases map runtime.GOOS/GOARCH to the names used by vendors in the registry
+var osAliases = map[string][]string{
+	"darwin": {"mac", "macos"},
+}
+
+var archAliases = map[string][]string{
+	"amd64": {"x64", "x86_64"},
+	"arm64": {"aarch64"},
+	"386":   {"x86-32", "x32", "x86"},
 }
 
 type RegistryData struct {
@@ -78,13 +145,12 @@ type RegistryData struct {
 // Handler is an entry point for the package_manager.go file
 
 func (man *Man) Handler() {
-	// check if DefaultLocation exists or not
-	homePath, err := os.UserHomeDir()
+	// check if the install root exists or not
+	defaultLocation, err := RootDir()
 	if err != nil {
-		fmt.Printf("Error getting home directory: %s\n", err)
+		fmt.Printf("Error getting the install root: %s\n", err)
 		os.Exit(1)
 	}
-	defaultLocation := filepath.Join(homePath, DefaultLocation)
 	if _, err := os.Stat(defaultLocation); os.IsNotExist(err) {
 		// get root directory
 		err := os.MkdirAll(defaultLocation, 0777)
@@ -94,23 +160,43 @@ func (man *Man) Handler() {
 		}
 	}
 
+	RecoverInterruptedInstalls()
+
+	var hookResults []HookResult
 	switch man.ActionType {
 	case "install":
+		firstVersion := len(man.installedVersions()) == 0
 		version := man.installNewVersion()
-		AddNewVersion(man.Candidate, version)
-		man.setDefaultVersion(version)
+		hookResults = man.runHooks(HookPostInstall, version)
+		switch {
+		case AutoDefault == configs.AutoDefaultAlways, AutoDefault == configs.AutoDefaultFirst && firstVersion:
+			hookResults = append(hookResults, man.makeDefaultVersion(version)...)
+		case AutoDefault == configs.AutoDefaultAsk:
+			hookResults = append(hookResults, man.setDefaultVersion(version)...)
+		}
+	case "remove":
+		man.removeVersion(man.Version)
 	case "list":
 		man.listOutAllVersion()
 	case "default":
-		man.setDefaultVersion("")
+		if man.Version != "" {
+			hookResults = man.makeDefaultVersion(man.resolveVersion(man.Version))
+		} else {
+			hookResults = man.setDefaultVersion("")
+		}
 
 	default:
 		fmt.Printf("Unsupported action type: %s\n", man.ActionType)
 	}
 
+	// the install or the default change is done, failed hooks are reported
+	if err := printHookResults(hookResults); err != nil {
+		os.Exit(1)
+	}
+
 }
 
-func (man *Man) setDefaultVersion(version string) {
+func (man *Man) setDefaultVersion(version string) []HookResult {
 	// ask user to enter the version if not provided
 	if version == "" {
 		version = tui.Input("Enter the version you want to set as default: ")
@@ -121,33 +207,82 @@ func (man *Man) setDefaultVersion(version string) {
 	} else {
 		confirm := tui.Input(fmt.Sprintf("Do you want to set %s as default version? [Y/N]", version))
 		if strings.ToLower(confirm) != "y" {
-			return
+			return nil
 		}
 	}
+	return man.makeDefaultVersion(man.resolveVersion(version))
+}
 
-	homePath, err := os.UserHomeDir()
+// resolveVersion returns the version an alias points to, or the version itself
+func (man *Man) resolveVersion(version string) string {
+	resolved, err := ResolveVersion(man.Candidate, version)
 	if err != nil {
-		fmt.Printf("Error getting home directory: %s\n", err)
+		fmt.Printf("Error reading the aliases: %s\n", err)
 		os.Exit(1)
 	}
+	return resolved
+}
 
-	defaultVersionLocation := homePath + fmt.Sprintf(DefaultVersionLocation, man.Candidate)
-	// reset default version to correct version
-	configData := LoadData()
-	currentDefaultVersion := ""
+// installedVersions returns the installed versions of the candidate
+func (man *Man) installedVersions() []string {
+	configData, err := LoadData()
+	if err != nil {
+		fmt.Printf("Error reading the installed versions: %s\n", err)
+		os.Exit(1)
+	}
 	for _, config := range configData {
 		if config.Candidate == man.Candidate {
-			currentDefaultVersion = config.Current
+			return config.Versions()
 		}
 	}
+	return nil
+}
 
-	err = os.Rename(defaultVersionLocation, fmt.Sprintf("%s%s/%s/%s", homePath, DefaultLocation, man.Candidate, currentDefaultVersion))
-	if err != nil && !os.IsNotExist(err) {
+// makeDefaultVersion moves the version directory to <candidate>/current and records it as the default. A
+// version of a shared root is linked instead, see shared.go. It returns the results of the post_default hooks.
+func (man *Man) makeDefaultVersion(version string) []HookResult {
+	configData, err := LoadData()
+	if err != nil {
+		fmt.Printf("Error reading the installed versions: %s\n", err)
+		os.Exit(1)
+	}
+	ownVersion := false
+	for _, config := range configData {
+		if installation, ok := config.Find(version); ok && config.Candidate == man.Candidate && installation.Root == "" {
+			ownVersion = true
+		}
+	}
+
+	if !ownVersion {
+		installation, ok, err := findSharedInstallation(man.Candidate, version)
+		if err != nil {
+			fmt.Printf("Error reading the shared versions: %s\n", err)
+			os.Exit(1)
+		}
+		if ok {
+			if err := linkSharedVersion(man.Candidate, installation); err != nil {
+				fmt.Printf("Error setting default version: %s\n", err)
+				os.Exit(1)
+			}
+			return runHooks(HookPostDefault, man.Candidate, installation)
+		}
+		fmt.Printf("%s %s is not installed\n", man.Candidate, version)
+		os.Exit(1)
+	}
+
+	// reset default version to correct version
+	if err := releaseCurrent(man.Candidate); err != nil {
 		fmt.Printf("Error setting default version: %s\n", err)
 		os.Exit(1)
 	}
 
-	newVersionLocation := fmt.Sprintf("%s%s/%s/%s", homePath, DefaultLocation, man.Candidate, version)
+	root, err := RootDir()
+	if err != nil {
+		fmt.Printf("Error getting the install root: %s\n", err)
+		os.Exit(1)
+	}
+	defaultVersionLocation := filepath.Join(root, fmt.Sprintf(DefaultVersionLocation, man.Candidate))
+	newVersionLocation := filepath.Join(root, man.Candidate, version)
 
 	err = os.Rename(newVersionLocation, defaultVersionLocation)
 	if err != nil && !os.IsNotExist(err) {
@@ -155,28 +290,105 @@ func (man *Man) setDefaultVersion(version string) {
 		os.Exit(1)
 	}
 	// update config file
-	UpdateDefaultVersionConfig(man.Candidate, version)
+	if err := UpdateDefaultVersionConfig(man.Candidate, version); err != nil {
+		fmt.Printf("Error setting default version: %s\n", err)
+		os.Exit(1)
+	}
+	return man.runHooks(HookPostDefault, version)
+}
+
+// runHooks runs the hooks of an event for an installed version
+func (man *Man) runHooks(event string, version string) []HookResult {
+	installation, ok, err := findInstallation(man.Candidate, version)
+	if err != nil || !ok {
+		return []HookResult{{Event: event, Err: fmt.Errorf("%s %s is not installed", man.Candidate, version)}}
+	}
+	return runHooks(event, man.Candidate, installation)
+}
+
+// removeVersion deletes an installed version once its pre_remove hooks succeeded. Versions deto didn't
+// install, e.g. the ones of a shared root, are only forgotten.
+func (man *Man) removeVersion(version string) {
+	if version == "" {
+		version = tui.Input("Enter the version you want to remove: ")
+		if version == "" {
+			fmt.Println("You didn't enter any version")
+			os.Exit(1)
+		}
+	}
+	version = man.resolveVersion(version)
+
+	configData, err := LoadData()
+	if err != nil {
+		fmt.Printf("Error reading the installed versions: %s\n", err)
+		os.Exit(1)
+	}
+	config := Config{Candidate: man.Candidate}
+	for _, candidateConfig := range configData {
+		if candidateConfig.Candidate == man.Candidate {
+			config = candidateConfig
+		}
+	}
+	installation, ok := config.Find(version)
+	if !ok {
+		fmt.Printf("%s %s is not installed\n", man.Candidate, version)
+		os.Exit(1)
+	}
+
+	if err := printHookResults(runHooks(HookPreRemove, man.Candidate, installation)); err != nil {
+		fmt.Printf("%s %s was not removed\n", man.Candidate, version)
+		os.Exit(1)
+	}
+
+	if err := removeInstallation(man.Candidate, installation, config.Current == version); err != nil {
+		fmt.Printf("Error removing %s %s: %s\n", man.Candidate, version, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s %s removed\n", man.Candidate, version)
+	// the aliases of the version are removed with it
+	for _, name := range slices.Sorted(maps.Keys(config.Aliases)) {
+		if config.Aliases[name] == version {
+			fmt.Printf("Alias %s removed\n", name)
+		}
+	}
 }
 
 func (man *Man) listOutAllVersion() {
 	defaultCol := []string{
 		"Version",
 		"Current",
+		"Shared root",
 	}
 
-	configData := LoadData()
+	configData, err := LoadData()
+	if err != nil {
+		fmt.Printf("Error reading the installed versions: %s\n", err)
+		os.Exit(1)
+	}
+	sharedData, err := sharedInstallations(man.Candidate)
+	if err != nil {
+		fmt.Printf("Error reading the shared versions: %s\n", err)
+		os.Exit(1)
+	}
 	var rows [][]string
 
-	for _, config := range configData {
-		if config.Candidate == man.Candidate {
-			for _, version := range config.Versions {
-				isCurrent := ""
-				if config.Current == version {
-					isCurrent = "Current"
-				}
-				rows = append(rows, []string{version, isCurrent})
-			}
-
+	config := Config{Candidate: man.Candidate}
+	for _, candidateConfig := range configData {
+		if candidateConfig.Candidate == man.Candidate {
+			config = candidateConfig
+		}
+	}
+	for _, installation := range config.Installations {
+		isCurrent := ""
+		if config.Current == installation.Version {
+			isCurrent = "Current"
+		}
+		rows = append(rows, []string{installation.Version, isCurrent, installation.Root})
+	}
+	// the shared versions the user hasn't used yet
+	for _, installation := range sharedData {
+		if _, ok := config.Find(installation.Version); !ok {
+			rows = append(rows, []string{installation.Version, "", installation.Root})
 		}
 	}
 
@@ -194,46 +406,215 @@ func (man *Man) installNewVersion() string {
 		os.Exit(1)
 	}
 
-	listItem := make([]string, 0)
-	for i, item := range data {
-		listItem = append(listItem, fmt.Sprintf("%d| %s - %s - %s - Is LTS: %t", i+1, item.Name, item.Version, item.Provider, item.IsLTS))
-	}
-	title := "Select the version you want to install"
-	selected := tui.InitList(listItem, title)
+	data = man.filterByImageType(data)
+	data = man.filterByVendor(data)
 
-	idx, _ := strconv.Atoi(strings.Split(selected, "|")[0])
+	var selectedItem RegistryVersion
+	if man.Version != "" {
+		item, ok := findRegistryVersion(data, man.resolveVersion(man.Version))
+		if !ok {
+			fmt.Printf("No version %s available for %s\n", man.Version, man.Candidate)
+			os.Exit(1)
+		}
+		selectedItem = item
+	} else {
+		listItem := make([]string, 0)
+		for i, item := range data {
+			listItem = append(listItem, fmt.Sprintf("%d| %s - %s - %s - Is LTS: %t", i+1, item.Name, item.InstallKey(), item.Provider, item.IsLTS))
+		}
+		title := "Select the version you want to install"
+		selected := tui.InitList(listItem, title)
 
-	if idx <= 0 {
-		fmt.Println("You didn't select any item")
-		os.Exit(1)
+		idx, _ := strconv.Atoi(strings.Split(selected, "|")[0])
+
+		if idx <= 0 {
+			fmt.Println("You didn't select any item")
+			os.Exit(1)
+		}
+		selectedItem = data[idx-1]
 	}
-	selectedItem := data[idx-1]
-	// try to download and verify checksum
-	isValid := DownloadAndVerify(selectedItem.Link, selectedItem.Checksum, "", selectedItem.Name)
+	version := selectedItem.InstallKey()
 
-	// extract the file
-	if isValid {
-		err := extractFile(selectedItem.Name, man.Candidate, selectedItem.Version)
-		if err != nil {
+	if man.Stream {
+		err := StreamInstall(selectedItem, man.Candidate)
+		if err == nil {
+			tui.Clear()
+			fmt.Println("Installation completed")
+			return version
+		}
+		if !errors.Is(err, errNotStreamable) {
 			fmt.Printf("\nError: %s\n", err)
 			os.Exit(1)
 		}
+		fmt.Printf("\n%s, downloading it first\n", err)
+	}
+
+	// try to download and verify checksum
+	filePath, err := DownloadAndVerify(selectedItem.Link, selectedItem.Checksum, selectedItem.ChecksumType, selectedItem.Name)
+	if err != nil {
+		fmt.Printf("\nError: %s\n", err)
+		os.Exit(1)
+	}
+	if err := VerifySignature(filePath, selectedItem); err != nil {
+		fmt.Printf("\nError: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := installArchive(filePath, man.Candidate, newInstallation(selectedItem)); err != nil {
+		fmt.Printf("\nError: %s\n", err)
+		os.Exit(1)
+	}
+	tui.Clear()
+	fmt.Println("Installation completed")
+
+	return version
+}
+
+// findRegistryVersion returns the entry installed as version, or else the first entry of that version, e.g.
+// the latest 21 when the registry lists 21.0.4-tem as version 21
+func findRegistryVersion(data []RegistryVersion, version string) (RegistryVersion, bool) {
+	if idx := slices.IndexFunc(data, func(item RegistryVersion) bool { return item.InstallKey() == version }); idx >= 0 {
+		return data[idx], true
+	}
+	idx := slices.IndexFunc(data, func(item RegistryVersion) bool {
+		return item.FullVersion == version || item.Version == version
+	})
+	if idx < 0 {
+		return RegistryVersion{}, false
+	}
+	return data[idx], true
+}
+
+// installArchive extracts the archive into a staging directory and commits it as the given version.
+// Nothing is left behind if the extraction fails or is interrupted.
+func installArchive(filePath string, candidate string, record Installation) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	return installWith(candidate, record, func(stagingDir string) error {
+		return extractFile(ctx, filePath, stagingDir, candidate)
+	})
+}
+
+// installWith stages a version with extract, records its manifest, then commits and records it. The staging
+// directory is dropped when any step fails.
+func installWith(candidate string, record Installation, extract func(stagingDir string) error) error {
+	journal, err := beginInstall(candidate, record)
+	if err != nil {
+		return err
+	}
+
+	if err := extract(journal.StagingDir); err != nil {
+		journal.rollback()
+		return err
+	}
+	if err := normalizeLayout(journal.StagingDir); err != nil {
+		journal.rollback()
+		return err
+	}
+	manifest, err := writeManifest(journal.StagingDir, candidate, record.Version)
+	if err != nil {
+		journal.rollback()
+		return err
+	}
+	journal.Record.Size = manifest.Size()
+	if err := journal.commit(); err != nil {
+		journal.rollback()
+		return err
+	}
+
+	// the version directory is in place, a failure here leaves the journal for RecoverInterruptedInstalls
+	if err := RecordInstall(candidate, journal.installation()); err != nil {
+		return err
+	}
+	journal.finish()
+	return nil
+}
+
+// filterByImageType keeps the versions of the requested image type (jdk, jre, debugimage, jdk-fx, ...)
+func (man *Man) filterByImageType(data []RegistryVersion) []RegistryVersion {
+	imageType := man.ImageType
+	if imageType == "" {
+		imageType = DefaultImageType
+	}
+
+	var result []RegistryVersion
+	var imageTypes []string
+	for _, item := range data {
+		itemImageType := item.ImageType
+		if itemImageType == "" {
+			itemImageType = DefaultImageType
+		}
+		if !slices.Contains(imageTypes, itemImageType) {
+			imageTypes = append(imageTypes, itemImageType)
+		}
+		if strings.EqualFold(itemImageType, imageType) {
+			result = append(result, item)
+		}
+	}
+
+	if len(result) == 0 {
+		fmt.Printf("No %s image available for this candidate, the registry has: %s\n", imageType, strings.Join(imageTypes, ", "))
+		os.Exit(1)
+	}
+	return result
+}
+
+// filterByVendor keeps the versions of a single vendor. When no vendor was given and the
+// registry offers more than one, the user picks one.
+func (man *Man) filterByVendor(data []RegistryVersion) []RegistryVersion {
+	var vendors []string
+	for _, item := range data {
+		if item.Vendor != "" && !slices.Contains(vendors, item.Vendor) {
+			vendors = append(vendors, item.Vendor)
+		}
+	}
+
+	vendor := man.Vendor
+	if vendor == "" {
+		if len(vendors) <= 1 {
+			return data
+		}
+		vendor = tui.GetChoice(vendors)
 		tui.Clear()
-		fmt.Println("Installation completed")
-	} else {
-		fmt.Println("Checksum is not valid")
+		if vendor == "" {
+			fmt.Println("You didn't select any vendor")
+			os.Exit(1)
+		}
+	}
+
+	var result []RegistryVersion
+	for _, item := range data {
+		if strings.EqualFold(item.Vendor, vendor) || strings.EqualFold(item.Provider, vendor) {
+			result = append(result, item)
+		}
+	}
+
+	if len(result) == 0 {
+		fmt.Printf("No version available from vendor: %s, the registry has: %s\n", vendor, strings.Join(vendors, ", "))
 		os.Exit(1)
 	}
+	return result
+}
 
-	_ = os.Remove(selectedItem.Name)
-	return selectedItem.Version
+// matchesPlatform reports whether a registry name (OS or architecture) matches the runtime one
+func matchesPlatform(registryName, runtimeName string, aliases map[string][]string) bool {
+	if strings.EqualFold(registryName, runtimeName) {
+		return true
+	}
+	for _, alias := range aliases[runtimeName] {
+		if strings.EqualFold(registryName, alias) {
+			return true
+		}
+	}
+	return false
 }
 
 func fetchRegistryData(man Man) []RegistryVersion {
 	msg := fmt.Sprintf("Starting checking data for OS: %s, Arch: %s", man.OperatingSystem, man.Architecture)
 	modelSpinner := tui.InitialSpinnerModel()
 	modelSpinner.Prompt = msg
-	p := tea.NewProgram(modelSpinner)
+	p := tea.NewProgram(modelSpinner, tui.ProgramOptions()...)
 	go func() {
 		if _, err := p.Run(); err != nil {
 			fmt.Println("Error running spinner:", err)
@@ -241,12 +622,50 @@ func fetchRegistryData(man Man) []RegistryVersion {
 		}
 	}()
 
-	url := fmt.Sprintf("https://raw.githubusercontent.com/halng/deto/refs/heads/main/registry/%s_versions.json", man.Candidate)
+	client, err := httpClient()
+	if err != nil {
+		fmt.Println("Error creating HTTP client:", err)
+		os.Exit(1)
+	}
+
+	// every source is tried through its mirrors, the registry of an entry is the source it comes from
+	type registryURL struct {
+		url      string
+		registry string
+	}
+	var urls []registryURL
+	for _, source := range RegistrySources {
+		registry := fmt.Sprintf(source, man.Candidate)
+		mirrored, err := linkURLs(registry)
+		if err != nil {
+			fmt.Println("Invalid mirrors config:", err)
+			os.Exit(1)
+		}
+		for _, candidateURL := range mirrored {
+			urls = append(urls, registryURL{url: candidateURL, registry: registry})
+		}
+	}
+	if len(urls) == 0 {
+		fmt.Println("No registry source configured")
+		os.Exit(1)
+	}
 
-	resp, err := http.Get(url)
+	// try the sources in order, the last response is handled below
+	var resp *http.Response
+	var url string
+	for i, candidateURL := range urls {
+		url = candidateURL.registry
+		resp, err = client.Get(candidateURL.url)
+		if err == nil && resp.StatusCode == http.StatusOK || i == len(urls)-1 {
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
 
 	if err != nil || resp != nil && resp.StatusCode == http.StatusNotFound {
-		tea.Printf("Candidate: %s are not supported. Please try again later.", man.Candidate)
+		fmt.Printf("Candidate: %s are not supported. Please try again later.\n", man.Candidate)
 		os.Exit(1)
 
 	}
@@ -254,7 +673,7 @@ func fetchRegistryData(man Man) []RegistryVersion {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		tea.Printf("Can not fetch data from registry. Error code %d", resp.StatusCode)
+		fmt.Printf("Can not fetch data from registry. Error code %d\n", resp.StatusCode)
 		os.Exit(1)
 	}
 
@@ -267,7 +686,11 @@ func fetchRegistryData(man Man) []RegistryVersion {
 	}
 	var result []RegistryVersion
 
-	if osData, ok := data[man.OperatingSystem].([]interface{}); ok {
+	for osName, rawData := range data {
+		osData, ok := rawData.([]interface{})
+		if !ok || !matchesPlatform(osName, man.OperatingSystem, osAliases) {
+			continue
+		}
 		for _, version := range osData {
 			var registry RegistryVersion
 			// Convert each `version` to JSON and then unmarshal it
@@ -281,7 +704,8 @@ func fetchRegistryData(man Man) []RegistryVersion {
 				os.Exit(1)
 			}
 
-			if strings.EqualFold(registry.Architecture, man.Architecture) {
+			if matchesPlatform(registry.Architecture, man.Architecture, archAliases) {
+				registry.Registry = url
 				result = append(result, registry)
 			}
 		}
@@ -290,71 +714,123 @@ func fetchRegistryData(man Man) []RegistryVersion {
 	return result
 }
 
-func DownloadAndVerify(url string, checksum string, algo string, name string) bool {
+// DownloadAndVerify returns the archive from the archive cache, or downloads it and verifies its checksum
+// before adding it to the cache. The configured mirrors are tried in order before the original url.
+// It returns the path of the archive.
+func DownloadAndVerify(url string, checksum string, algo string, name string) (string, error) {
 	if algo == "" {
 		algo = "sha256"
 	}
+	if cachedPath, ok := lookupCachedArchive(checksum, algo, name); ok {
+		return cachedPath, nil
+	}
+
+	urls, err := linkURLs(url)
+	if err != nil {
+		return "", fmt.Errorf("invalid mirrors config: %w", err)
+	}
+
+	var filePath string
+	for _, candidateURL := range urls {
+		filePath, err = downloadAndVerifyFrom(candidateURL, checksum, algo, name)
+		if err == nil || errors.Is(err, errDownloadInterrupted) {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cachedPath, err := addToArchiveCache(filePath, checksum, algo, name)
+	if err != nil {
+		fmt.Println("Error caching file:", err)
+		return filePath, nil
+	}
+	return cachedPath, nil
+}
+
+// downloadAndVerifyFrom downloads the file from a single url and verifies its checksum
+func downloadAndVerifyFrom(url string, checksum string, algo string, name string) (string, error) {
 	// Download the file
 	filePath, err := downloadFile(url, name)
 	if err != nil {
-		fmt.Println("Error downloading file:", err)
-		return false
+		return "", fmt.Errorf("error downloading file from %s: %w", url, err)
 	}
 	// Verify checksum
 	valid, err := verifyChecksum(filePath, checksum, algo)
 	if err != nil {
-		fmt.Println("Error verifying checksum:", err)
-		return false
+		return "", fmt.Errorf("error verifying checksum: %w", err)
 	}
-
-	return valid
+	if !valid {
+		_ = os.Remove(filePath)
+		return "", fmt.Errorf("checksum of the file from %s is not valid", url)
+	}
+	return filePath, nil
 }
 
-// downloadFile downloads a file from a URL and saves it locally
+// downloadFile downloads a file from a URL into the download cache, resuming a previous partial download.
+// Quitting the progress bar, Ctrl+C or SIGTERM cancel the download and keep the partial file.
 func downloadFile(url string, name string) (string, error) {
-	tui.Clear()
-	resp, err := http.Get(url)
+	downloader, err := NewDownloader()
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.ContentLength <= 0 {
-		fmt.Println("can't parse content length, aborting download")
-		os.Exit(1)
+	var filePath string
+	err = withDownloadProgress(func(ctx context.Context, onProgress func(int64, int64)) error {
+		downloader.OnProgress = onProgress
+		var err error
+		filePath, err = downloader.Download(ctx, url, name)
+		return err
+	})
+	if errors.Is(err, context.Canceled) {
+		return "", errDownloadInterrupted
 	}
-
-	filePath := name
-	file, err := os.Create(filePath)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
+	return filePath, nil
+}
 
-	var p *tea.Program
-	pw := &tui.DownloadProgressWriter{
-		Total:  int(resp.ContentLength),
-		File:   file,
-		Reader: resp.Body,
-		OnProgress: func(ratio float64) {
-			p.Send(tui.ProgressMsg(ratio))
-		},
-	}
+// withDownloadProgress runs work while showing the download progress bar. Quitting the progress bar,
+// Ctrl+C or SIGTERM cancel the context of work.
+func withDownloadProgress(work func(ctx context.Context, onProgress func(downloaded int64, total int64)) error) error {
+	tui.Clear()
 
-	m := tui.DownloadProgressModel{
-		Pw:       pw,
-		Progress: progress.New(progress.WithDefaultGradient()),
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var p *tea.Program
+	onProgress := func(downloaded int64, total int64) {
+		if total > 0 {
+			p.Send(tui.ProgressMsg(float64(downloaded) / float64(total)))
+		} else {
+			// chunked responses don't tell the size, show what we got so far
+			p.Send(tui.ProgressStatusMsg(fmt.Sprintf("%s downloaded", FormatBytes(downloaded))))
+		}
 	}
 
-	p = tea.NewProgram(m)
+	p = tea.NewProgram(tui.NewDownloadProgressModel(), tui.ProgramOptions(tea.WithContext(ctx))...)
 
-	go pw.Start(p)
+	done := make(chan error, 1)
+	go func() {
+		err := work(ctx, onProgress)
+		if err != nil {
+			p.Send(tui.ProgressErrMsg{Err: err})
+		} else {
+			p.Send(tui.ProgressDoneMsg{})
+		}
+		done <- err
+	}()
 
-	if _, err := p.Run(); err != nil {
-		fmt.Println("error running program:", err)
-		os.Exit(1)
+	_, runErr := p.Run()
+	if runErr != nil && !errors.Is(runErr, tea.ErrProgramKilled) {
+		// the progress bar can't run, e.g. without a terminal, the work goes on without it
+		return <-done
 	}
-	return filePath, nil
+	// stop the work if the program ended before it, e.g. the user quit
+	cancel()
+	return <-done
 }
 
 // verifyChecksum calculates the checksum of a file and compares it with the expected checksum
@@ -363,7 +839,7 @@ func verifyChecksum(filePath, expectedChecksum, algo string) (bool, error) {
 	msg := fmt.Sprintf("Verify checksum of %s", filePath)
 	modelSpinner := tui.InitialSpinnerModel()
 	modelSpinner.Prompt = msg
-	p := tea.NewProgram(modelSpinner)
+	p := tea.NewProgram(modelSpinner, tui.ProgramOptions()...)
 	go func() {
 		if _, err := p.Run(); err != nil {
 			fmt.Println("Error running spinner:", err)
@@ -377,120 +853,28 @@ func verifyChecksum(filePath, expectedChecksum, algo string) (bool, error) {
 	}
 	defer file.Close()
 
-	var hash []byte
-	switch algo {
-	case "sha256":
-		hasher := sha256.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return false, err
-		}
-		hash = hasher.Sum(nil)
-	case "sha512":
-		hasher := sha512.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return false, err
-		}
-		hash = hasher.Sum(nil)
-	default:
-		return false, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	hasher, err := newHasher(algo)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(hasher, file); err != nil {
+		return false, err
 	}
 
 	// Convert hash to a hex string
-	checksum := hex.EncodeToString(hash)
+	checksum := hex.EncodeToString(hasher.Sum(nil))
 	return checksum == expectedChecksum, nil
 }
 
-func extractFile(fileName string, candidate string, version string) error {
-	tui.Clear()
-	userHome, _ := os.UserHomeDir()
-	finalDest := filepath.Join(userHome, DefaultLocation, candidate, version)
-	msg := fmt.Sprintf("Extracting from %s to %s ...", fileName, finalDest)
-	modelSpinner := tui.InitialSpinnerModel()
-	modelSpinner.Prompt = msg
-	p := tea.NewProgram(modelSpinner)
-	go func() {
-		if _, err := p.Run(); err != nil {
-			fmt.Println("Error running spinner:", err)
-			os.Exit(1)
-		}
-	}()
-
-	defer p.Send(tea.Quit())
-
-	if strings.Contains(fileName, ".tar.gz") {
-		return decompressTarGz(fileName, finalDest)
-	}
-	return fmt.Errorf("unsupported file format: %s", fileName)
-}
-
-func decompressTarGz(src, finalDest string) error {
-	// Open the tar.gz file
-
-	file, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Create a gzip reader on the opened file
-	gzipReader, err := gzip.NewReader(file)
-	if err != nil {
-		return err
-	}
-	defer gzipReader.Close()
-
-	// Create a tar reader on top of the gzip reader
-	tarReader := tar.NewReader(gzipReader)
-
-	// Iterate over the tar file entries
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break // End of archive
-		}
-		if err != nil {
-			return err
-		}
-
-		// Validate the file path
-		if strings.Contains(header.Name, "..") {
-			return fmt.Errorf("invalid file path: %s", header.Name)
-		}
-
-		// Construct the full file path
-		targetPath := filepath.Join(finalDest, header.Name)
-		targetPath = filepath.Clean(targetPath)
-
-		// Ensure the target path is within the final destination
-		if !strings.HasPrefix(targetPath, filepath.Clean(finalDest)+string(os.PathSeparator)) {
-			return fmt.Errorf("invalid file path: %s", header.Name)
-		}
-
-		// Check the type of entry
-		switch header.Typeflag {
-		case tar.TypeDir:
-			// Make directory if not exists
-			if err := os.MkdirAll(targetPath, 0777); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			// Make the file and write its content
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0777); err != nil {
-				return err
-			}
-			outFile, err := os.Create(targetPath)
-			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return err
-			}
-			outFile.Close()
-		default:
-			log.Printf("Unable to handle file type %c in tar file", header.Typeflag)
-		}
+func newHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
 	}
-
-	return nil
 }
@@ -1,5 +1,73 @@
 package pkg
 
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DetoHome is the install root, DETO_HOME, --home or home in the config. ~/.devtools is used when it is empty.
+var DetoHome = ""
 var DefaultLocation = "/.devtools"
+
+// CacheHome is the cache root, DETO_CACHE_DIR or cache.dir in the config. It doesn't follow the install root, so
+// that every install root shares the cached archives. ~/.devtools/cache is used when it is empty.
+var CacheHome = ""
+var DefaultCacheLocation = "/.devtools/cache"
+
+// the locations below are relative to the cache root
+var DefaultDownloadLocation = "downloads"
+var DefaultArchiveCacheLocation = "archives"
+
+// the locations below are relative to the install root
 var DefaultConfigFile = "deto.json"
-var DefaultVersionLocation = DefaultLocation + "/%s/current"
+var DefaultVersionLocation = "%s/current"
+
+// DefaultCacheMaxSize is the size cap of the archive cache, the least recently used archives are evicted above it
+var DefaultCacheMaxSize int64 = 5 << 30
+var DefaultStagingLocation = ".staging"
+var DefaultJournalLocation = ".journal"
+var DefaultKeysLocation = "keys"
+
+// RequireSignatures refuses to install registry entries that don't reference a signature
+var RequireSignatures = false
+
+// RootDir returns the absolute path of the install root
+func RootDir() (string, error) {
+	return absDir(DetoHome, DefaultLocation)
+}
+
+// CacheDir returns the absolute path of the cache root
+func CacheDir() (string, error) {
+	return absDir(CacheHome, DefaultCacheLocation)
+}
+
+// absDir returns the absolute path of dir, with ~ expanded, or of defaultLocation in the user's home when dir is
+// empty
+func absDir(dir string, defaultLocation string) (string, error) {
+	if dir == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(userHome, defaultLocation), nil
+	}
+
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(userHome, dir[1:])
+	}
+	return filepath.Abs(dir)
+}
+
+// rootPath joins elem to the install root
+func rootPath(elem ...string) (string, error) {
+	root, err := RootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{root}, elem...)...), nil
+}
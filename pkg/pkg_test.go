@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRootDir(t *testing.T) {
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct{ home, want string }{
+		{"", filepath.Join(userHome, DefaultLocation)},
+		{"/opt/deto", "/opt/deto"},
+		{"~/tools", filepath.Join(userHome, "tools")},
+	}
+	defer func() { DetoHome = "" }()
+	for _, test := range tests {
+		DetoHome = test.home
+		if got, err := RootDir(); err != nil || got != filepath.FromSlash(test.want) {
+			t.Errorf("DetoHome %q: got %q, %v, want %q", test.home, got, err, test.want)
+		}
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct{ home, cacheHome, want string }{
+		{"", "", filepath.Join(userHome, DefaultCacheLocation)},
+		// the cache doesn't follow the install root
+		{"/opt/deto", "", filepath.Join(userHome, DefaultCacheLocation)},
+		{"/opt/deto", "/var/cache/deto", "/var/cache/deto"},
+		{"", "~/cache", filepath.Join(userHome, "cache")},
+	}
+	defer func() { DetoHome, CacheHome = "", "" }()
+	for _, test := range tests {
+		DetoHome, CacheHome = test.home, test.cacheHome
+		if got, err := CacheDir(); err != nil || got != filepath.FromSlash(test.want) {
+			t.Errorf("DetoHome %q, CacheHome %q: got %q, %v, want %q", test.home, test.cacheHome, got, err, test.want)
+		}
+	}
+}
+
+func TestStateStoreInRelocatedRoot(t *testing.T) {
+	DetoHome = t.TempDir()
+	defer func() { DetoHome = "" }()
+
+	if err := RecordInstall("go", Installation{Version: "go1.23", Path: installPath("go", "go1.23")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(DetoHome, DefaultConfigFile)); err != nil {
+		t.Fatalf("deto.json is not in the install root: %v", err)
+	}
+
+	dir, err := VersionDir("go", "go1.23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(DetoHome, "go", "go1.23"); dir != want {
+		t.Fatalf("got %s, want %s", dir, want)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package pkg
+
+import "os"
+
+// processExists reports whether a process with the given pid is running
+func processExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// FindProcess opens a handle to the process on windows and fails when it doesn't exist
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
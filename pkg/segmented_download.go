@@ -0,0 +1,226 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// == In this file, we download large files as several ranges fetched concurrently. == //
+// Every segment writes into its own region of the .part file, and the progress of each segment is kept
+// in the .meta file so that an interrupted download resumes every segment where it stopped.
+
+// errRangeChanged means the server stopped honoring our ranges, e.g. the file changed in the meantime
+var errRangeChanged = errors.New("the server doesn't honor the requested range anymore")
+
+// downloadSegment is the inclusive byte range [Start, End] and the number of bytes of it already written
+type downloadSegment struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (s *downloadSegment) remaining() int64 {
+	return s.End - s.Start + 1 - s.Done
+}
+
+// probeSegments asks for the first byte of url to learn whether the server supports ranges and how big the
+// file is. It returns nil when the file should be downloaded as a single stream.
+func (d *Downloader) probeSegments(ctx context.Context, url string) (*downloadMeta, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1))
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		// no range support, the single stream request handles the status code
+		return nil, nil
+	}
+	_, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil || total < 2*d.MinSegmentSize {
+		return nil, nil
+	}
+
+	count := int64(d.Parallelism)
+	if maxCount := total / d.MinSegmentSize; count > maxCount {
+		count = maxCount
+	}
+	segmentSize := (total + count - 1) / count
+
+	meta := &downloadMeta{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Size:         total,
+	}
+	if !meta.canResume(url) {
+		// without a validator, the segments could come from different versions of the file
+		return nil, nil
+	}
+	for start := int64(0); start < total; start += segmentSize {
+		end := min(start+segmentSize, total) - 1
+		meta.Segments = append(meta.Segments, downloadSegment{Start: start, End: end})
+	}
+	return meta, nil
+}
+
+// downloadSegments fetches the missing part of every segment concurrently into dest.part
+func (d *Downloader) downloadSegments(ctx context.Context, url string, dest string, meta *downloadMeta) (string, error) {
+	partPath := dest + ".part"
+	metaPath := dest + ".meta"
+
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	if err := file.Truncate(meta.Size); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := saveDownloadMeta(metaPath, *meta); err != nil {
+		file.Close()
+		return "", err
+	}
+
+	var mu sync.Mutex
+	var downloaded int64
+	for _, segment := range meta.Segments {
+		downloaded += segment.Done
+	}
+	report := func(n int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		downloaded += n
+		if d.OnProgress != nil {
+			d.OnProgress(downloaded, meta.Size)
+		}
+	}
+
+	segmentCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(meta.Segments))
+	for i := range meta.Segments {
+		segment := &meta.Segments[i]
+		if segment.remaining() <= 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.downloadSegment(segmentCtx, url, meta, segment, file, report); err != nil {
+				// one failed segment fails the attempt, the others stop and keep their progress
+				cancel()
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// report the cause rather than the cancellation of the sibling segments
+	var downloadErr error
+	for err := range errs {
+		if downloadErr == nil || errors.Is(downloadErr, context.Canceled) || errors.Is(err, errRangeChanged) {
+			downloadErr = err
+		}
+	}
+
+	closeErr := file.Close()
+	if errors.Is(downloadErr, errRangeChanged) {
+		// the segments can't be trusted anymore, the next attempt starts over
+		_ = os.Remove(partPath)
+		_ = os.Remove(metaPath)
+		return "", downloadErr
+	}
+	if downloadErr != nil {
+		_ = saveDownloadMeta(metaPath, *meta)
+		return "", downloadErr
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+
+	if err := os.Rename(partPath, dest); err != nil {
+		return "", err
+	}
+	_ = os.Remove(metaPath)
+	return dest, nil
+}
+
+// downloadSegment fetches the remaining bytes of segment and writes them at their offset in file
+func (d *Downloader) downloadSegment(ctx context.Context, url string, meta *downloadMeta, segment *downloadSegment, file *os.File, report func(int64)) error {
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := segment.Start + segment.Done
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, segment.End))
+	setIfRange(req, meta)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if rangeStart, _, err := parseContentRange(resp.Header.Get("Content-Range")); err != nil || rangeStart != start {
+			return errRangeChanged
+		}
+	case http.StatusOK:
+		return errRangeChanged
+	default:
+		return &httpStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	body := io.Reader(resp.Body)
+	var timedOut func() bool
+	if d.ReadTimeout > 0 {
+		body, timedOut = newIdleTimeoutReader(resp.Body, d.ReadTimeout, cancel)
+	}
+	body = io.LimitReader(body, segment.remaining())
+
+	buf := make([]byte, 32*1024)
+	for segment.remaining() > 0 {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := file.WriteAt(buf[:n], segment.Start+segment.Done); err != nil {
+				return err
+			}
+			segment.Done += int64(n)
+			report(int64(n))
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			if timedOut != nil && timedOut() && ctx.Err() == nil {
+				return errReadTimeout
+			}
+			return readErr
+		}
+	}
+
+	if segment.remaining() > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeServer serves content and records the Range header of every request
+type rangeServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	ranges []string
+}
+
+func newRangeServer(t *testing.T, content []byte, handler func(w http.ResponseWriter, r *http.Request) bool) *rangeServer {
+	s := &rangeServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mu.Unlock()
+		if handler != nil && handler(w, r) {
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rangeServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.ranges)
+}
+
+func newSegmentedTestDownloader(t *testing.T, server *rangeServer) *Downloader {
+	d := newTestDownloader(t, server.Server)
+	d.Parallelism = 4
+	d.MinSegmentSize = 64 << 10
+	return d
+}
+
+func checkDownloaded(t *testing.T, path string, err error, content []byte) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("got %d bytes that differ from the %d bytes served", len(got), len(content))
+	}
+}
+
+func TestSegmentedDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64<<10)
+	server := newRangeServer(t, content, nil)
+
+	path, err := newSegmentedTestDownloader(t, server).Download(context.Background(), server.URL, "file.tar.gz")
+	checkDownloaded(t, path, err, content)
+
+	want := []string{"bytes=0-0", "bytes=0-262143", "bytes=262144-524287", "bytes=524288-786431", "bytes=786432-1048575"}
+	got := server.requests()
+	slices.Sort(got[1:])
+	if !slices.Equal(got, want) {
+		t.Fatalf("got requests with ranges %q, want %q", got, want)
+	}
+}
+
+func TestSegmentedDownloadResumesSegments(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 16<<10)
+	server := newRangeServer(t, content, nil)
+	d := newSegmentedTestDownloader(t, server)
+
+	// the first segment stopped after 1000 bytes, the second one is complete
+	dest := filepath.Join(d.Dir, "file.tar.gz")
+	part := make([]byte, len(content))
+	copy(part[:1000], content)
+	copy(part[131072:], content[131072:])
+	if err := os.WriteFile(dest+".part", part, 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta := downloadMeta{URL: server.URL, ETag: `"v1"`, Size: int64(len(content)), Segments: []downloadSegment{
+		{Start: 0, End: 131071, Done: 1000},
+		{Start: 131072, End: 262143, Done: 131072},
+	}}
+	if err := saveDownloadMeta(dest+".meta", meta); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := d.Download(context.Background(), server.URL, "file.tar.gz")
+	checkDownloaded(t, path, err, content)
+	if got := server.requests(); !slices.Equal(got, []string{"bytes=1000-131071"}) {
+		t.Fatalf("got requests with ranges %q, want the rest of the first segment only", got)
+	}
+	if _, err := os.Stat(dest + ".meta"); !os.IsNotExist(err) {
+		t.Fatalf("the meta file is left behind: %v", err)
+	}
+}
+
+func TestSegmentedDownloadFallsBack(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64<<10)
+	tests := []struct {
+		name    string
+		want    []byte
+		handler func(w http.ResponseWriter, r *http.Request) bool
+	}{
+		{"range ignored", content, func(w http.ResponseWriter, r *http.Request) bool {
+			// 200 with the whole file, even to the bytes=0-0 probe
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(content)
+			return true
+		}},
+		{"no validator", content, func(w http.ResponseWriter, r *http.Request) bool {
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			return true
+		}},
+		{"small file", content[:100<<10], func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content[:100<<10]))
+			return true
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newRangeServer(t, content, test.handler)
+			path, err := newSegmentedTestDownloader(t, server).Download(context.Background(), server.URL, "file.tar.gz")
+			checkDownloaded(t, path, err, test.want)
+			if got := server.requests(); !slices.Equal(got, []string{"bytes=0-0", ""}) {
+				t.Fatalf("got requests with ranges %q, want the probe and a single stream", got)
+			}
+		})
+	}
+}
+
+func TestSegmentedDownloadRestartsWhenRangesChange(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64<<10)
+	changed := false
+	var mu sync.Mutex
+	server := newRangeServer(t, content, func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Range") != "bytes=0-0" && !changed {
+			// the file changed after the probe, the If-Range of the segments doesn't match
+			changed = true
+			w.Header().Set("ETag", `"v2"`)
+			w.Write(content)
+			return true
+		}
+		return false
+	})
+
+	d := newSegmentedTestDownloader(t, server)
+	if _, err := d.Download(context.Background(), server.URL, "file.tar.gz"); err == nil {
+		t.Fatal("expected the changed file to fail the download")
+	}
+	dest := filepath.Join(d.Dir, "file.tar.gz")
+	for _, path := range []string{dest + ".part", dest + ".meta"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s is kept after the ranges changed: %v", filepath.Base(path), err)
+		}
+	}
+
+	path, err := d.Download(context.Background(), server.URL, "file.tar.gz")
+	checkDownloaded(t, path, err, content)
+}
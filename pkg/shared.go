@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// == In this file, we read the installs of shared roots. == //
+// A shared root, e.g. /opt/deto, is an install root that an administrator fills with DETO_HOME=/opt/deto and
+// that users can only read. Its versions are listed next to the ones of the user's install root and can
+// become the user's default version: <root>/<candidate>/current is then a symlink to the shared version
+// directory <shared>/<candidate>/<version>, nothing is copied. The default version of a shared root lives in
+// <shared>/<candidate>/current, whose tree changes with the default of the shared root, so it can't be linked.
+// Shared roots should be filled with install.auto_default = never, so that their version directories never
+// move to current.
+
+// SharedRoots are the read-only install roots whose versions are available to the user
+var SharedRoots []string
+
+// sharedInstallations returns the versions of the candidate installed in the shared roots. A version found in
+// several roots is returned for the first one only.
+func sharedInstallations(candidate string) ([]Installation, error) {
+	root, err := RootDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var installations []Installation
+	seen := map[string]bool{}
+	for _, sharedRoot := range SharedRoots {
+		sharedRoot, err := filepath.Abs(sharedRoot)
+		if err != nil {
+			return nil, err
+		}
+		if sharedRoot == root {
+			continue
+		}
+
+		// the root is read-only, its lock file can't be taken. deto.json is always replaced atomically,
+		// so it is never read half written.
+		store := &StateStore{path: filepath.Join(sharedRoot, DefaultConfigFile)}
+		configs, _, _, err := store.read()
+		if err != nil {
+			return nil, fmt.Errorf("can not read the shared root %s: %w", sharedRoot, err)
+		}
+		for _, config := range configs {
+			if config.Candidate != candidate {
+				continue
+			}
+			for _, installation := range config.Installations {
+				// the shared versions of a shared root are not shared any further
+				if installation.Root != "" || seen[installation.Version] {
+					continue
+				}
+				seen[installation.Version] = true
+				installation.Root = sharedRoot
+				installation.ExternallyManaged = true
+				installations = append(installations, installation)
+			}
+		}
+	}
+	return installations, nil
+}
+
+// findSharedInstallation returns the shared installation of a version
+func findSharedInstallation(candidate string, version string) (Installation, bool, error) {
+	installations, err := sharedInstallations(candidate)
+	if err != nil {
+		return Installation{}, false, err
+	}
+	installation, ok := Config{Candidate: candidate, Installations: installations}.Find(version)
+	return installation, ok, nil
+}
+
+// installationDir returns the version directory of an installation
+func installationDir(installation Installation) (string, error) {
+	if installation.Root != "" {
+		return filepath.Join(installation.Root, filepath.FromSlash(installation.Path)), nil
+	}
+	return rootPath(filepath.FromSlash(installation.Path))
+}
+
+// linkSharedVersion makes the shared installation the default version of the user: the directory of the
+// previous default goes back to <candidate>/<version> and current becomes a symlink to the shared directory
+func linkSharedVersion(candidate string, installation Installation) error {
+	if installation.Path == currentPath(candidate) {
+		return fmt.Errorf("%s %s is the default version of the shared root %s, its directory changes with that default",
+			candidate, installation.Version, installation.Root)
+	}
+	// the version directory, whatever the record says, so the link never follows the default of the shared root
+	sharedDir := filepath.Join(installation.Root, filepath.FromSlash(installPath(candidate, installation.Version)))
+	if _, err := os.Stat(sharedDir); err != nil {
+		return err
+	}
+
+	if err := releaseCurrent(candidate); err != nil {
+		return err
+	}
+	currentDir, err := rootPath(fmt.Sprintf(DefaultVersionLocation, candidate))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(currentDir), 0755); err != nil {
+		return err
+	}
+	if err := os.Symlink(sharedDir, currentDir); err != nil {
+		return err
+	}
+
+	if err := RecordInstall(candidate, installation); err != nil {
+		return err
+	}
+	return UpdateDefaultVersionConfig(candidate, installation.Version)
+}
+
+// releaseCurrent empties <candidate>/current: a link to a shared version is removed and the directory of a
+// version of the user is moved back to <candidate>/<version>
+func releaseCurrent(candidate string) error {
+	currentDir, err := rootPath(fmt.Sprintf(DefaultVersionLocation, candidate))
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(currentDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(currentDir)
+	}
+
+	configs, err := LoadData()
+	if err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if config.Candidate == candidate && config.Current != "" {
+			versionDir, err := rootPath(candidate, config.Current)
+			if err != nil {
+				return err
+			}
+			return os.Rename(currentDir, versionDir)
+		}
+	}
+	return nil
+}
+
+// findInstallation returns the installation of a version of the user, or else of a shared root
+func findInstallation(candidate string, version string) (Installation, bool, error) {
+	configs, err := LoadData()
+	if err != nil {
+		return Installation{}, false, err
+	}
+	for _, config := range configs {
+		if installation, ok := config.Find(version); ok && config.Candidate == candidate {
+			return installation, true, nil
+		}
+	}
+	return findSharedInstallation(candidate, version)
+}
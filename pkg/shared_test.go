@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinkedSharedVersionIgnoresSharedDefault(t *testing.T) {
+	sharedRoot, userRoot := t.TempDir(), t.TempDir()
+	defer func() { DetoHome, SharedRoots = "", nil }()
+
+	// the administrator fills the shared root
+	DetoHome = sharedRoot
+	admin := &Man{Candidate: "go"}
+	installTestVersion(t, "go", "1.0", "one")
+	installTestVersion(t, "go", "2.0", "two")
+	installTestVersion(t, "go", "3.0", "three")
+	admin.makeDefaultVersion("1.0")
+
+	DetoHome, SharedRoots = userRoot, []string{sharedRoot}
+	installation, ok, err := findSharedInstallation("go", "1.0")
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v for the shared default", ok, err)
+	}
+	if err := linkSharedVersion("go", installation); err == nil {
+		t.Fatal("linked the default version of the shared root")
+	}
+
+	installation, ok, err = findSharedInstallation("go", "2.0")
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v for go 2.0", ok, err)
+	}
+	if err := linkSharedVersion("go", installation); err != nil {
+		t.Fatal(err)
+	}
+	tool := filepath.Join(userRoot, "go", "current", "bin", "tool")
+	if got, err := os.ReadFile(tool); err != nil || string(got) != "two" {
+		t.Fatalf("got %q, %v, want the tree of go 2.0", got, err)
+	}
+
+	// the administrator switches the shared default, the user's default doesn't move
+	DetoHome = sharedRoot
+	admin.makeDefaultVersion("3.0")
+	DetoHome = userRoot
+	if got, err := os.ReadFile(tool); err != nil || string(got) != "two" {
+		t.Fatalf("got %q, %v after the shared default changed, want the tree of go 2.0", got, err)
+	}
+}
@@ -0,0 +1,178 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/halng/deto/tui"
+)
+
+// == In this file, we verify the detached signatures of downloaded artifacts. == //
+// Registry entries may reference a signature (signature_url), its type (pgp or minisign) and the fingerprint
+// of the key that signs them (key_fingerprint). The key must be in the keyring, see keyring.go. An artifact
+// whose signature doesn't verify is never installed.
+
+const maxSignatureSize = 1 << 20
+
+var errSignatureInvalid = errors.New("signature verification failed")
+
+// checkSignaturePolicy refuses unsigned entries when signatures are required
+func checkSignaturePolicy(item RegistryVersion) error {
+	if item.SignatureURL == "" && RequireSignatures {
+		return fmt.Errorf("%s is not signed and signatures are required", item.Name)
+	}
+	return nil
+}
+
+// signatureType returns the signature type of an entry, guessed from the signature url when not set
+func signatureType(item RegistryVersion) string {
+	if item.SignatureType != "" {
+		return item.SignatureType
+	}
+	if strings.HasSuffix(item.SignatureURL, ".minisig") {
+		return SignatureTypeMinisign
+	}
+	return SignatureTypePGP
+}
+
+// VerifySignature verifies the file downloaded for item against its detached signature
+func VerifySignature(filePath string, item RegistryVersion) error {
+	if err := checkSignaturePolicy(item); err != nil {
+		return err
+	}
+	if item.SignatureURL == "" {
+		return nil
+	}
+
+	signature, err := fetchSignature(item.SignatureURL)
+	if err != nil {
+		return err
+	}
+
+	tui.Clear()
+	modelSpinner := tui.InitialSpinnerModel()
+	modelSpinner.Prompt = fmt.Sprintf("Verify signature of %s", filePath)
+	p := tea.NewProgram(modelSpinner, tui.ProgramOptions()...)
+	go func() {
+		if _, err := p.Run(); err != nil {
+			fmt.Println("Error running spinner:", err)
+			os.Exit(1)
+		}
+	}()
+	defer p.Send(tea.Quit())
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch signatureType(item) {
+	case SignatureTypePGP:
+		err = verifyPGPSignature(file, signature, item.KeyFingerprint)
+	case SignatureTypeMinisign:
+		err = verifyMinisignSignature(file, signature, item.KeyFingerprint)
+	default:
+		return fmt.Errorf("unsupported signature type: %s", item.SignatureType)
+	}
+	if err != nil {
+		return fmt.Errorf("%w for %s: %w", errSignatureInvalid, item.Name, err)
+	}
+	return nil
+}
+
+func verifyPGPSignature(signed io.Reader, signature []byte, fingerprint string) error {
+	keyring, err := loadPGPKeyring(fingerprint)
+	if err != nil {
+		return err
+	}
+	if len(keyring) == 0 {
+		return missingKeyError(fingerprint)
+	}
+
+	var signer *openpgp.Entity
+	if bytes.Contains(signature, []byte("-----BEGIN PGP")) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, signed, bytes.NewReader(signature), nil)
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, signed, bytes.NewReader(signature), nil)
+	}
+	if err != nil {
+		return err
+	}
+	if fingerprint != "" && pgpFingerprint(signer) != normalizeFingerprint(fingerprint) {
+		return fmt.Errorf("signed by %s instead of %s", pgpFingerprint(signer), normalizeFingerprint(fingerprint))
+	}
+	return nil
+}
+
+func verifyMinisignSignature(signed io.Reader, signature []byte, fingerprint string) error {
+	sig, err := parseMinisignSignature(signature)
+	if err != nil {
+		return err
+	}
+	keyID := minisignKeyID(sig.KeyID)
+	if fingerprint != "" {
+		if fingerprint, err = checkFingerprint(fingerprint, SignatureTypeMinisign); err != nil {
+			return err
+		}
+		if keyID != fingerprint {
+			return fmt.Errorf("signed by %s instead of %s", keyID, fingerprint)
+		}
+	}
+
+	key, err := loadMinisignKey(keyID)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return missingKeyError(keyID)
+	}
+	return key.verify(signed, sig)
+}
+
+func missingKeyError(fingerprint string) error {
+	if fingerprint == "" {
+		return errors.New("no trusted OpenPGP key, import the publisher key with: deto key import <file>")
+	}
+	return fmt.Errorf("key %s is not trusted, import it with: deto key import <file>", normalizeFingerprint(fingerprint))
+}
+
+// fetchSignature downloads a detached signature, trying the mirrors first
+func fetchSignature(url string) ([]byte, error) {
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+	urls, err := linkURLs(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirrors config: %w", err)
+	}
+
+	for _, candidateURL := range urls {
+		var signature []byte
+		signature, err = fetchSignatureFrom(client, candidateURL)
+		if err == nil {
+			return signature, nil
+		}
+	}
+	return nil, fmt.Errorf("error downloading the signature: %w", err)
+}
+
+func fetchSignatureFrom(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+}
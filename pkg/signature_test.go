@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"golang.org/x/crypto/blake2b"
+)
+
+// importTestKey writes a key file and imports it into the keyring
+func importTestKey(t *testing.T, data []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportKey(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestPGPKey(t *testing.T) (*openpgp.Entity, []byte) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("deto test", "", "test@deto.dev", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writer, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(writer); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	return entity, buf.Bytes()
+}
+
+func TestVerifyPGPSignature(t *testing.T) {
+	DetoHome = t.TempDir()
+	defer func() { DetoHome = "" }()
+
+	trusted, publicKey := newTestPGPKey(t)
+	importTestKey(t, publicKey)
+	unknown, _ := newTestPGPKey(t)
+
+	content := []byte("the archive")
+	sign := func(entity *openpgp.Entity) []byte {
+		var signature bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader(content), nil); err != nil {
+			t.Fatal(err)
+		}
+		return signature.Bytes()
+	}
+
+	tests := []struct {
+		name        string
+		content     []byte
+		signature   []byte
+		fingerprint string
+		wantErr     string
+	}{
+		{"good signature", content, sign(trusted), pgpFingerprint(trusted), ""},
+		{"good signature, any trusted key", content, sign(trusted), "", ""},
+		{"bad signature", []byte("another archive"), sign(trusted), pgpFingerprint(trusted), "invalid signature"},
+		{"unknown key", content, sign(unknown), pgpFingerprint(unknown), "not trusted"},
+		{"signed by another key", content, sign(unknown), "", "signature made by unknown entity"},
+		{"traversal fingerprint", content, sign(trusted), "../../../etc/passwd", "invalid pgp key fingerprint"},
+	}
+	for _, test := range tests {
+		err := verifyPGPSignature(bytes.NewReader(test.content), test.signature, test.fingerprint)
+		if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: got %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+// testMinisignKey is a minisign key pair
+type testMinisignKey struct {
+	keyID   [8]byte
+	private ed25519.PrivateKey
+}
+
+func newTestMinisignKey(t *testing.T) testMinisignKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testMinisignKey{private: private}
+	if _, err := rand.Read(key.keyID[:]); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func (key testMinisignKey) publicKey() []byte {
+	raw := append([]byte("Ed"), key.keyID[:]...)
+	raw = append(raw, key.private.Public().(ed25519.PublicKey)...)
+	return []byte(fmt.Sprintf("untrusted comment: minisign public key\n%s\n", base64.StdEncoding.EncodeToString(raw)))
+}
+
+// sign signs the BLAKE2b-512 hash of content, the way minisign -S does
+func (key testMinisignKey) sign(content []byte) []byte {
+	hash := blake2b.Sum512(content)
+	signature := ed25519.Sign(key.private, hash[:])
+	raw := append([]byte("ED"), key.keyID[:]...)
+	raw = append(raw, signature...)
+	trustedComment := "timestamp:0\tfile:archive"
+	global := ed25519.Sign(key.private, append(signature, trustedComment...))
+	return []byte(fmt.Sprintf("untrusted comment: signature\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(raw), trustedComment, base64.StdEncoding.EncodeToString(global)))
+}
+
+func TestVerifyMinisignSignature(t *testing.T) {
+	DetoHome = t.TempDir()
+	defer func() { DetoHome = "" }()
+
+	trusted := newTestMinisignKey(t)
+	importTestKey(t, trusted.publicKey())
+	unknown := newTestMinisignKey(t)
+
+	content := []byte("the archive")
+	tests := []struct {
+		name        string
+		content     []byte
+		signature   []byte
+		fingerprint string
+		wantErr     string
+	}{
+		{"good signature", content, trusted.sign(content), minisignKeyID(trusted.keyID), ""},
+		{"good signature, key of the signature", content, trusted.sign(content), "", ""},
+		{"bad signature", []byte("another archive"), trusted.sign(content), minisignKeyID(trusted.keyID), "invalid minisign signature"},
+		{"unknown key", content, unknown.sign(content), minisignKeyID(unknown.keyID), "not trusted"},
+		{"signed by another key", content, unknown.sign(content), minisignKeyID(trusted.keyID), "signed by"},
+		{"traversal fingerprint", content, trusted.sign(content), "../../../etc/passwd", "invalid minisign key fingerprint"},
+	}
+	for _, test := range tests {
+		err := verifyMinisignSignature(bytes.NewReader(test.content), test.signature, test.fingerprint)
+		if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: got %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestLoadKeysRejectsTraversal(t *testing.T) {
+	DetoHome = t.TempDir()
+	defer func() { DetoHome = "" }()
+
+	// a key file outside of the keys directory, in the place a traversal would reach
+	_, publicKey := newTestPGPKey(t)
+	if err := os.WriteFile(filepath.Join(DetoHome, "EVIL"+pgpKeyExt), publicKey, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if keyring, err := loadPGPKeyring("../EVIL"); err == nil {
+		t.Fatalf("loaded %d keys through a traversal fingerprint", len(keyring))
+	}
+	if _, err := loadMinisignKey("../../0123456789ABCDEF"); err == nil {
+		t.Fatal("loaded a minisign key through a traversal key id")
+	}
+	if err := RemoveKey("../EVIL"); err == nil {
+		t.Fatal("removed a file through a traversal fingerprint")
+	}
+}
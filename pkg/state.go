@@ -0,0 +1,325 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// == In this file, we keep the state of the installs in deto.json. == //
+// Every change goes through StateStore.Update, which holds an exclusive lock on deto.json.lock while it reads
+// the state, applies the change and writes the result to a temp file renamed over deto.json. The previous
+// state is kept in deto.json.bak, and a deto.json that can't be read is recovered from it. Readers take a
+// shared lock, so they never see a half written file. Files of older schemas are migrated on load, see
+// migrations.go.
+
+// State is the content of deto.json
+type State struct {
+	SchemaVersion int      `json:"schema_version"`
+	Candidates    []Config `json:"candidates"`
+}
+
+// Config is the state of a candidate
+type Config struct {
+	Candidate     string         `json:"candidate"`
+	Current       string         `json:"current"`
+	Installations []Installation `json:"installations"`
+	// Aliases are names of versions, see alias.go
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// Installation is an installed version of a candidate
+type Installation struct {
+	Version string `json:"version"`
+	// Path is the version directory, relative to the install root and slash separated
+	Path string `json:"path"`
+	// Root is the shared root of a version the user didn't install, see shared.go. Path is relative to it.
+	Root string `json:"root,omitempty"`
+	// Home is the home directory of the version, relative to Path
+	Home         string    `json:"home,omitempty"`
+	Vendor       string    `json:"vendor,omitempty"`
+	Provider     string    `json:"provider,omitempty"`
+	SourceURL    string    `json:"source_url,omitempty"`
+	Checksum     string    `json:"checksum,omitempty"`
+	ChecksumType string    `json:"checksum_type,omitempty"`
+	InstalledAt  time.Time `json:"installed_at,omitzero"`
+	Size         int64     `json:"size,omitempty"`
+	// Registry is the registry the version was picked from
+	Registry string `json:"registry,omitempty"`
+	// ExternallyManaged marks versions that deto records but didn't install, it never deletes them
+	ExternallyManaged bool `json:"externally_managed,omitempty"`
+	// Hooks are the registry hooks of the version, see hooks.go
+	Hooks *Hooks `json:"hooks,omitempty"`
+}
+
+// Versions returns the installed versions of the candidate
+func (c Config) Versions() []string {
+	versions := make([]string, 0, len(c.Installations))
+	for _, installation := range c.Installations {
+		versions = append(versions, installation.Version)
+	}
+	return versions
+}
+
+// Find returns the installation of a version
+func (c Config) Find(version string) (Installation, bool) {
+	for _, installation := range c.Installations {
+		if installation.Version == version {
+			return installation, true
+		}
+	}
+	return Installation{}, false
+}
+
+// installPath returns the Path of a version that isn't the default one
+func installPath(candidate string, version string) string {
+	return candidate + "/" + version
+}
+
+// currentPath returns the Path of the default version, see DefaultVersionLocation
+func currentPath(candidate string) string {
+	return candidate + "/current"
+}
+
+// StateStore reads and writes deto.json
+type StateStore struct {
+	path string
+}
+
+// NewStateStore returns the store of the deto.json of the install root
+func NewStateStore() (*StateStore, error) {
+	root, err := RootDir()
+	if err != nil {
+		return nil, err
+	}
+	return &StateStore{path: filepath.Join(root, DefaultConfigFile)}, nil
+}
+
+func (s *StateStore) lockPath() string {
+	return s.path + ".lock"
+}
+
+func (s *StateStore) backupPath() string {
+	return s.path + ".bak"
+}
+
+// stateMu serializes the stores of this process, file locks only exclude other processes
+var stateMu sync.Mutex
+
+// lock takes the lock of the store, creating the install root when needed
+func (s *StateStore) lock(exclusive bool) (func(), error) {
+	stateMu.Lock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		stateMu.Unlock()
+		return nil, err
+	}
+	unlock, err := lockFile(s.lockPath(), exclusive)
+	if err != nil {
+		stateMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		stateMu.Unlock()
+	}, nil
+}
+
+// Load returns the current state
+func (s *StateStore) Load() ([]Config, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	configs, _, _, err := s.read()
+	return configs, err
+}
+
+// Update applies change to the state and saves the result atomically. Nothing is written when change fails.
+func (s *StateStore) Update(change func(configs []Config) ([]Config, error)) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	configs, previous, schemaVersion, err := s.read()
+	if err != nil {
+		return err
+	}
+	configs, err = change(configs)
+	if err != nil {
+		return err
+	}
+
+	byteData, err := json.Marshal(State{SchemaVersion: CurrentSchemaVersion, Candidates: configs})
+	if err != nil {
+		return err
+	}
+	if previous != nil && schemaVersion != CurrentSchemaVersion {
+		// keep the file of the old schema for good, older deto releases can still read it
+		if err := writeFileAtomic(fmt.Sprintf("%s.schema-%d.bak", s.path, schemaVersion), previous); err != nil {
+			return err
+		}
+	}
+	if previous != nil {
+		if err := writeFileAtomic(s.backupPath(), previous); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(s.path, byteData)
+}
+
+// read parses and migrates deto.json, falling back to the backup when it is corrupted. It also returns the
+// content that was parsed, so that it can become the next backup, and its schema version.
+func (s *StateStore) read() ([]Config, []byte, int, error) {
+	fileBytes, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Config{}, nil, CurrentSchemaVersion, nil
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	state, schemaVersion, parseErr := decodeState(fileBytes)
+	if parseErr == nil {
+		return state.Candidates, fileBytes, schemaVersion, nil
+	}
+	if errors.Is(parseErr, errNewerSchema) {
+		return nil, nil, 0, parseErr
+	}
+
+	backupBytes, err := os.ReadFile(s.backupPath())
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%s is corrupted and has no backup: %w", s.path, parseErr)
+	}
+	state, schemaVersion, err = decodeState(backupBytes)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%s and its backup are corrupted: %w", s.path, parseErr)
+	}
+	fmt.Printf("%s is corrupted, using the backup %s\n", s.path, s.backupPath())
+	return state.Candidates, backupBytes, schemaVersion, nil
+}
+
+// writeFileAtomic writes data to a temp file next to path, syncs it and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadData returns the state of the installs
+func LoadData() ([]Config, error) {
+	store, err := NewStateStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Load()
+}
+
+func updateData(change func(configs []Config) ([]Config, error)) error {
+	store, err := NewStateStore()
+	if err != nil {
+		return err
+	}
+	return store.Update(change)
+}
+
+// UpdateDefaultVersionConfig records the default version of a candidate, whose directory was renamed to
+// <candidate>/current
+func UpdateDefaultVersionConfig(candidate string, defaultVersion string) error {
+	return updateData(func(configs []Config) ([]Config, error) {
+		for i, config := range configs {
+			if config.Candidate != candidate {
+				continue
+			}
+			configs[i].Current = defaultVersion
+			for j, installation := range config.Installations {
+				if installation.Root != "" {
+					// a shared version is linked, its directory doesn't move
+					continue
+				}
+				if installation.Version == defaultVersion {
+					configs[i].Installations[j].Path = currentPath(candidate)
+				} else if installation.Path == currentPath(candidate) {
+					configs[i].Installations[j].Path = installPath(candidate, installation.Version)
+				}
+			}
+			break
+		}
+		return configs, nil
+	})
+}
+
+// RecordInstall records an installed version. It becomes the default version with UpdateDefaultVersionConfig,
+// once its directory is moved to <candidate>/current.
+func RecordInstall(candidate string, installation Installation) error {
+	return updateData(func(configs []Config) ([]Config, error) {
+		idx := slices.IndexFunc(configs, func(config Config) bool { return config.Candidate == candidate })
+		if idx < 0 {
+			configs = append(configs, Config{Candidate: candidate})
+			idx = len(configs) - 1
+		}
+
+		// a reinstall of the same version replaces its directory, keep a single record
+		installations := configs[idx].Installations
+		if i := slices.IndexFunc(installations, func(existing Installation) bool { return existing.Version == installation.Version }); i >= 0 {
+			installations[i] = installation
+		} else {
+			configs[idx].Installations = append(installations, installation)
+		}
+		return configs, nil
+	})
+}
+
+// ForgetInstall removes the record of a version, the aliases that point to it, and the default version of the
+// candidate when it was the one
+func ForgetInstall(candidate string, version string) error {
+	return updateData(func(configs []Config) ([]Config, error) {
+		for i, config := range configs {
+			if config.Candidate != candidate {
+				continue
+			}
+			if config.Current == version {
+				configs[i].Current = ""
+			}
+			configs[i].Installations = slices.DeleteFunc(config.Installations, func(installation Installation) bool {
+				return installation.Version == version
+			})
+			maps.DeleteFunc(configs[i].Aliases, func(name string, target string) bool {
+				return target == version
+			})
+			if len(configs[i].Installations) == 0 && len(configs[i].Aliases) == 0 {
+				configs = slices.Delete(configs, i, i+1)
+			}
+			break
+		}
+		return configs, nil
+	})
+}
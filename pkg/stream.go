@@ -0,0 +1,175 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// == In this file, we install archives while they download. == //
+// The response body goes through the checksum hasher and the decompressor straight into the staging directory
+// of the install, so the archive is never written to disk. The staging directory is committed only once the
+// whole body is read and its checksum matches, otherwise it is dropped. Streamed archives skip the archive
+// cache and can't be resumed, an interrupted stream starts over. Zip archives need random access and are
+// downloaded first.
+
+var (
+	errNotStreamable     = errors.New("the archive format can't be streamed")
+	errChecksumMismatch  = errors.New("checksum is not valid")
+	errStreamInterrupted = errors.New("install interrupted")
+)
+
+// StreamInstall downloads and extracts a version in one pass. It returns errNotStreamable, before anything is
+// installed, when the archive has to be downloaded first.
+func StreamInstall(item RegistryVersion, candidate string) error {
+	algo := item.ChecksumType
+	if algo == "" {
+		algo = "sha256"
+	}
+	record := newInstallation(item)
+
+	if err := checkSignaturePolicy(item); err != nil {
+		return err
+	}
+	if item.SignatureURL != "" {
+		return fmt.Errorf("%w: the signature must be verified before extraction", errNotStreamable)
+	}
+
+	if cachedPath, ok := lookupCachedArchive(item.Checksum, algo, item.Name); ok {
+		return installArchive(cachedPath, candidate, record)
+	}
+
+	urls, err := linkURLs(item.Link)
+	if err != nil {
+		return fmt.Errorf("invalid mirrors config: %w", err)
+	}
+
+	for _, url := range urls {
+		err = installWith(candidate, record, func(stagingDir string) error {
+			return withDownloadProgress(func(ctx context.Context, onProgress func(int64, int64)) error {
+				return streamArchive(ctx, url, item.Checksum, algo, stagingDir, candidate, onProgress)
+			})
+		})
+		if errors.Is(err, context.Canceled) {
+			return errStreamInterrupted
+		}
+		if err == nil || errors.Is(err, errNotStreamable) {
+			return err
+		}
+		err = fmt.Errorf("error streaming %s: %w", url, err)
+	}
+	return err
+}
+
+// streamArchive extracts the archive at url into finalDest, retrying transient failures from scratch
+func streamArchive(ctx context.Context, url string, checksum string, algo string, finalDest string, binaryName string, onProgress func(int64, int64)) error {
+	backoff := DefaultRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := streamArchiveOnce(ctx, url, checksum, algo, finalDest, binaryName, onProgress)
+		if err == nil || attempt >= DefaultRetries || errors.Is(err, errNotStreamable) ||
+			errors.Is(err, errChecksumMismatch) || errors.Is(err, errUnsafeEntry) || !isRetryable(ctx, err) {
+			return err
+		}
+		if err := clearDir(finalDest); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func streamArchiveOnce(ctx context.Context, url string, checksum string, algo string, finalDest string, binaryName string, onProgress func(int64, int64)) error {
+	hasher, err := newHasher(algo)
+	if err != nil {
+		return err
+	}
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+
+	// the request is cancelled when the server stops sending data for DefaultReadTimeout
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	body, timedOut := newIdleTimeoutReader(resp.Body, DefaultReadTimeout, cancel)
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = resp.ContentLength
+	}
+	pw := &progressWriter{total: total, onProgress: onProgress}
+	reader := bufio.NewReaderSize(io.TeeReader(io.TeeReader(body, hasher), pw), magicSize)
+
+	err = extractStream(reqCtx, reader, finalDest, binaryName)
+	if err == nil {
+		// the decompressor may stop before the end of the body, e.g. at the tar end marker, the checksum
+		// covers every byte
+		_, err = io.Copy(io.Discard, reader)
+	}
+	if err != nil {
+		if timedOut() && ctx.Err() == nil {
+			return errReadTimeout
+		}
+		return err
+	}
+
+	if hex.EncodeToString(hasher.Sum(nil)) != checksum {
+		return fmt.Errorf("%w for the file from %s", errChecksumMismatch, url)
+	}
+	return nil
+}
+
+// extractStream detects the format from the first bytes of reader and extracts it
+func extractStream(ctx context.Context, reader *bufio.Reader, finalDest string, binaryName string) error {
+	magic, err := reader.Peek(magicSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	format, ok := matchArchiveFormat(magic)
+	if !ok {
+		return errors.New("unsupported file format")
+	}
+	if format.Stream == nil {
+		return fmt.Errorf("%w: %s", errNotStreamable, format.Name)
+	}
+	return format.Stream(ctx, reader, finalDest, binaryName)
+}
+
+// clearDir removes the content of dir, keeping dir itself
+func clearDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamInstall(t *testing.T) {
+	tarball := compressTest(t, testTarball(t), func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })
+	zipArchive, err := os.ReadFile(writeTestZip(t, []zipTestEntry{{name: "bin/tool", mode: 0755, content: "tool"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := func(content []byte) string { return fmt.Sprintf("%x", sha256.Sum256(content)) }
+
+	tests := []struct {
+		name         string
+		content      []byte
+		checksum     string
+		dropFirst    bool
+		wantErr      error
+		wantRequests int
+	}{
+		{"tarball", tarball, checksum(tarball), false, nil, 1},
+		{"dropped connection starts over", tarball, checksum(tarball), true, nil, 2},
+		{"checksum mismatch", tarball, checksum([]byte("another archive")), false, errChecksumMismatch, 1},
+		{"zip", zipArchive, checksum(zipArchive), false, errNotStreamable, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			DetoHome, CacheHome, DefaultRetryBackoff = t.TempDir(), t.TempDir(), time.Millisecond
+			defer func() { DetoHome, CacheHome, DefaultRetryBackoff = "", "", time.Second }()
+
+			var mu sync.Mutex
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				first := requests == 1
+				mu.Unlock()
+				if test.dropFirst && first {
+					// send the start of the archive, then drop the connection
+					w.Header().Set("Content-Length", fmt.Sprint(len(test.content)))
+					w.Write(test.content[:len(test.content)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				w.Write(test.content)
+			}))
+			defer server.Close()
+
+			item := RegistryVersion{Version: "1.0", Name: "tool.tar.gz", Checksum: test.checksum, Link: server.URL + "/tool.tar.gz"}
+			err := StreamInstall(item, "tool")
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got %v, want %v", err, test.wantErr)
+			}
+			if requests != test.wantRequests {
+				t.Fatalf("got %d requests, want %d", requests, test.wantRequests)
+			}
+
+			got, readErr := os.ReadFile(filepath.Join(DetoHome, "tool", "1.0", "bin", "tool"))
+			if test.wantErr == nil && (readErr != nil || string(got) != "tool") {
+				t.Fatalf("got bin/tool %q, %v, want the extracted file", got, readErr)
+			}
+			if _, found, _ := findInstallation("tool", "1.0"); found != (test.wantErr == nil) {
+				t.Fatalf("got the install recorded: %v, want %v", found, test.wantErr == nil)
+			}
+			if test.wantErr != nil {
+				if _, err := os.Stat(filepath.Join(DetoHome, "tool")); !os.IsNotExist(err) {
+					t.Fatalf("the failed install left tool/ behind: %v", err)
+				}
+			}
+
+			// streamed archives never reach the archive cache
+			if _, ok := lookupCachedArchive(test.checksum, "sha256", item.Name); ok {
+				t.Fatal("the streamed archive is in the archive cache")
+			}
+		})
+	}
+}
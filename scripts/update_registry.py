@@ -3,11 +3,68 @@ import json
 
 # ============================================ Java Versions ============================================
 # This script fetches all available Java versions from different providers and saves them to a JSON file
-# Supported providers: Adoptium
+# Supported providers: Adoptium (tem), Amazon Corretto (amzn), Azul Zulu (zulu), BellSoft Liberica (librca),
+# GraalVM Community Edition (graal)
+# Every entry carries a vendor-qualified identifier such as 21.0.4-tem which deto uses as the install key
+# and an image_type: jdk, jre, debugimage, or jdk-fx/jre-fx for JavaFX-bundled builds
 # =======================================================================================================
 
+# Platforms we publish for the non-Adoptium vendors, using the Adoptium naming that the registry already uses
+JAVA_PLATFORMS = [
+    ("linux", "x64"),
+    ("linux", "aarch64"),
+    ("mac", "x64"),
+    ("mac", "aarch64"),
+    ("windows", "x64"),
+]
 
-def _adoptium():
+
+# Adoptium publishes JRE and debug images next to the JDK
+ADOPTIUM_IMAGE_TYPES = ["jdk", "jre", "debugimage"]
+
+# Adoptium signs every package with this OpenPGP key, see https://adoptium.net/docs/verify-binaries/
+ADOPTIUM_KEY_FINGERPRINT = "3B04D753C9050D9A5D343F39843C48A565F8F04B"
+
+
+def _java_identifier(java_version, vendor, image_type="jdk"):
+    """
+    Builds the vendor-qualified identifier, e.g. 21.0.4-tem, or 21.0.4-tem-jre for non JDK images
+    """
+    if image_type == "jdk":
+        return f"{java_version}-{vendor}"
+    return f"{java_version}-{vendor}-{image_type}"
+
+
+def _java_entry(os_name, arch, version, full_version, java_version, vendor, provider, name, link, checksum,
+                is_lts, image_type="jdk", checksum_type="sha256"):
+    """
+    Builds a registry entry for a Java package. Keeps the key order used in java_versions.json
+    """
+    entry = {
+        "architecture": arch,
+        "checksum": checksum,
+        "link": link,
+        "name": name,
+        "version": str(version),
+        "full_version": full_version,
+        "vendor": vendor,
+        "identifier": _java_identifier(java_version, vendor, image_type),
+        "image_type": image_type,
+        "is_lts": is_lts,
+        "provider": provider,
+    }
+    if checksum_type != "sha256":
+        entry["checksum_type"] = checksum_type
+    return os_name, entry
+
+
+def _add_entry(all_version, os_name, entry):
+    if os_name not in all_version:
+        all_version[os_name] = []
+    all_version[os_name].append(entry)
+
+
+def _adoptium(all_version):
     """ 
     Fetches all available Java versions from Adoptium and saves them to a JSON file
     Only includes versions from the available_releases list to ensure validity
@@ -15,13 +72,12 @@ def _adoptium():
     Docs: https://api.adoptium.net/q/swagger-ui/
     """
     GET_ALL_VERSIONS_API = "https://api.adoptium.net/v3/info/available_releases"
-    GET_VERSION_DETAILS_API = "https://api.adoptium.net/v3/assets/feature_releases/{version}/ga?image_type=jdk"
+    GET_VERSION_DETAILS_API = "https://api.adoptium.net/v3/assets/feature_releases/{version}/ga?image_type={image_type}"
 
     req = requests.get(GET_ALL_VERSIONS_API)
     versions = req.json()
 
     available_versions = versions["available_releases"]
-    all_version = {}
     # Dictionary to track versions by major version and OS/arch combination
     version_tracking = {}
 
@@ -31,46 +87,59 @@ def _adoptium():
             print(f"Skipping invalid version: {version}")
             continue
             
-        print(f"Fetching details for Java {version} from Adoptium...")
-        req = requests.get(GET_VERSION_DETAILS_API.format(version=version))
-        
-        # Validate API response
-        if req.status_code != 200:
-            print(f"Failed to fetch details for Java {version}, status code: {req.status_code}")
-            continue
-            
-        details = req.json()
-        for obj in details:
-            binaries = obj["binaries"]
-            for binary in binaries:
-                # Validate required fields exist
-                if not all(k in binary for k in ['os', 'architecture', 'package']):
-                    print(f"Skipping binary with missing fields for Java {version}")
-                    continue
-                
-                os_name = binary['os']
-                arch = binary["architecture"]
-                
-                # Track versions for deduplication
-                key = (version, os_name, arch)
-                package_name = binary["package"]["name"]
-                
-                if key not in version_tracking:
-                    version_tracking[key] = []
-                
-                version_tracking[key].append({
-                    "architecture": arch,
-                    "checksum": binary["package"]["checksum"],
-                    "link": binary["package"]["link"],
-                    "name": package_name,
-                    "version": str(version),
-                    "is_lts": version in versions["available_lts_releases"],
-                    "provider": "Adoptium",
-                    "update_version": obj.get("version_data", {}).get("openjdk_version", package_name)
-                })
-
-    # Now filter to keep only the 2 latest versions for each major version/os/arch combo
-    for (major_version, os_name, arch), entries in version_tracking.items():
+        for image_type in ADOPTIUM_IMAGE_TYPES:
+            print(f"Fetching details for Java {version} ({image_type}) from Adoptium...")
+            req = requests.get(GET_VERSION_DETAILS_API.format(version=version, image_type=image_type))
+
+            # Validate API response
+            if req.status_code != 200:
+                print(f"Failed to fetch details for Java {version}, status code: {req.status_code}")
+                continue
+
+            details = req.json()
+            for obj in details:
+                binaries = obj["binaries"]
+                for binary in binaries:
+                    # Validate required fields exist
+                    if not all(k in binary for k in ['os', 'architecture', 'package']):
+                        print(f"Skipping binary with missing fields for Java {version}")
+                        continue
+
+                    os_name = binary['os']
+                    arch = binary["architecture"]
+
+                    # Track versions for deduplication
+                    key = (version, image_type, os_name, arch)
+                    package_name = binary["package"]["name"]
+
+                    if key not in version_tracking:
+                        version_tracking[key] = []
+
+                    version_data = obj.get("version_data", {})
+                    java_version = _adoptium_java_version(version_data, version)
+                    version_tracking[key].append({
+                        "architecture": arch,
+                        "checksum": binary["package"]["checksum"],
+                        "link": binary["package"]["link"],
+                        "name": package_name,
+                        "version": str(version),
+                        "full_version": obj.get("release_name", java_version).removeprefix("jdk-").removeprefix("jdk"),
+                        "vendor": "tem",
+                        "identifier": _java_identifier(java_version, "tem", image_type),
+                        "image_type": image_type,
+                        "is_lts": version in versions["available_lts_releases"],
+                        "provider": "Adoptium",
+                        "update_version": version_data.get("openjdk_version", package_name)
+                    })
+                    if binary["package"].get("signature_link"):
+                        version_tracking[key][-1].update({
+                            "signature_url": binary["package"]["signature_link"],
+                            "signature_type": "pgp",
+                            "key_fingerprint": ADOPTIUM_KEY_FINGERPRINT,
+                        })
+
+    # Now filter to keep only the 2 latest versions for each major version/image/os/arch combo
+    for (major_version, image_type, os_name, arch), entries in version_tracking.items():
         # Sort by update version (extract the patch/build number from the name)
         # Names follow pattern like: OpenJDK8U-jdk_x64_linux_hotspot_8u472b08.tar.gz
         # We want to sort by the version number (e.g., 8u472b08)
@@ -80,23 +149,199 @@ def _adoptium():
         latest_entries = sorted_entries[:2]
         
         for entry in latest_entries:
-            if os_name not in all_version:
-                all_version[os_name] = []
-            
             # Remove the temporary update_version field
             entry_to_add = {k: v for k, v in entry.items() if k != 'update_version'}
-            all_version[os_name].append(entry_to_add)
+            _add_entry(all_version, os_name, entry_to_add)
+
+
+def _adoptium_java_version(version_data, feature_version):
+    """
+    Returns the dotted java version (e.g. 21.0.4, 8.0.412 or 25) from Adoptium version_data
+    """
+    major = version_data.get("major", feature_version)
+    minor = version_data.get("minor", 0)
+    security = version_data.get("security", 0)
+    if minor == 0 and security == 0:
+        return str(major)
+    return f"{major}.{minor}.{security}"
+
+
+def _corretto(all_version, lts_versions):
+    """
+    Fetches the latest Amazon Corretto build of every LTS version
+    The latest link redirects to the versioned resource, which gives us the full version
+    Docs: https://docs.aws.amazon.com/corretto/latest/corretto-21-ug/downloads-list.html
+    """
+    LATEST_LINK = "https://corretto.aws/downloads/latest/amazon-corretto-{version}-{arch}-{os}-jdk.{ext}"
+    LATEST_SHA256 = "https://corretto.aws/downloads/latest_sha256/amazon-corretto-{version}-{arch}-{os}-jdk.{ext}"
+    CORRETTO_OS = {"linux": "linux", "mac": "macos", "windows": "windows"}
+
+    for version in lts_versions:
+        print(f"Fetching details for Java {version} from Amazon Corretto...")
+        for os_name, arch in JAVA_PLATFORMS:
+            ext = "zip" if os_name == "windows" else "tar.gz"
+            params = {"version": version, "arch": arch, "os": CORRETTO_OS[os_name], "ext": ext}
+            head = requests.head(LATEST_LINK.format(**params), allow_redirects=False)
+            link = head.headers.get("Location")
+            if head.status_code not in (301, 302) or not link:
+                continue
+            checksum = requests.get(LATEST_SHA256.format(**params))
+            if checksum.status_code != 200:
+                continue
+
+            # e.g. https://corretto.aws/downloads/resources/21.0.4.7.1/amazon-corretto-21.0.4.7.1-linux-x64.tar.gz
+            full_version = link.split("/")[-2]
+            parts = full_version.split(".")
+            java_version = ".".join(parts[:3]) if version != 8 else f"8.0.{parts[1]}"
+            _add_entry(all_version, *_java_entry(
+                os_name, arch, version, full_version, java_version, "amzn", "Amazon Corretto",
+                link.split("/")[-1], link, checksum.text.strip(), True))
+
+
+def _zulu(all_version, available_versions, lts_versions):
+    """
+    Fetches the latest Azul Zulu build of every available version
+    Docs: https://api.azul.com/metadata/v1/docs/swagger
+    """
+    PACKAGES_API = "https://api.azul.com/metadata/v1/zulu/packages/"
+    PACKAGE_DETAILS_API = "https://api.azul.com/metadata/v1/zulu/packages/{uuid}"
+    ZULU_OS = {"linux": "linux", "mac": "macos", "windows": "windows"}
+    ZULU_ARCH = {"x64": "x64", "aarch64": "aarch64"}
+    # image_type -> (java_package_type, javafx_bundled)
+    ZULU_IMAGE_TYPES = {
+        "jdk": ("jdk", "false"),
+        "jre": ("jre", "false"),
+        "jdk-fx": ("jdk", "true"),
+        "jre-fx": ("jre", "true"),
+    }
+
+    for version in available_versions:
+        print(f"Fetching details for Java {version} from Azul Zulu...")
+        for (os_name, arch), (image_type, (package_type, javafx_bundled)) in (
+                (platform, image) for platform in JAVA_PLATFORMS for image in ZULU_IMAGE_TYPES.items()):
+            req = requests.get(PACKAGES_API, params={
+                "java_version": version,
+                "os": ZULU_OS[os_name],
+                "arch": ZULU_ARCH[arch],
+                "archive_type": "zip" if os_name == "windows" else "tar.gz",
+                "java_package_type": package_type,
+                "javafx_bundled": javafx_bundled,
+                "release_status": "ga",
+                "availability_types": "CA",
+                "latest": "true",
+            })
+            if req.status_code != 200 or not req.json():
+                continue
+            package = req.json()[0]
+            details = requests.get(PACKAGE_DETAILS_API.format(uuid=package["package_uuid"]))
+            if details.status_code != 200:
+                continue
+
+            java_version = ".".join(str(v) for v in package["java_version"][:3])
+            full_version = f"{java_version}+{package.get('openjdk_build_number', 0)}"
+            _add_entry(all_version, *_java_entry(
+                os_name, arch, version, full_version, java_version, "zulu", "Azul Zulu",
+                package["name"], package["download_url"], details.json()["sha256_hash"], version in lts_versions,
+                image_type))
+
+
+def _liberica(all_version, available_versions):
+    """
+    Fetches the latest BellSoft Liberica build of every available version
+    Liberica only publishes sha1 checksums, so the entries carry checksum_type
+    Docs: https://api.bell-sw.com/api.html
+    """
+    RELEASES_API = "https://api.bell-sw.com/v1/liberica/releases"
+    LIBERICA_OS = {"linux": "linux", "mac": "macos", "windows": "windows"}
+    LIBERICA_ARCH = {"x64": "x86", "aarch64": "arm"}
+    # image_type -> bundle-type, the "full" bundles include JavaFX
+    LIBERICA_IMAGE_TYPES = {"jdk": "jdk", "jre": "jre", "jdk-fx": "jdk-full", "jre-fx": "jre-full"}
+
+    for version in available_versions:
+        print(f"Fetching details for Java {version} from BellSoft Liberica...")
+        for (os_name, arch), (image_type, bundle_type) in (
+                (platform, image) for platform in JAVA_PLATFORMS for image in LIBERICA_IMAGE_TYPES.items()):
+            req = requests.get(RELEASES_API, params={
+                "version-feature": version,
+                "version-modifier": "latest",
+                "bitness": 64,
+                "os": LIBERICA_OS[os_name],
+                "arch": LIBERICA_ARCH[arch],
+                "bundle-type": bundle_type,
+                "package-type": "zip" if os_name == "windows" else "tar.gz",
+                "release-type": "all",
+            })
+            if req.status_code != 200 or not req.json():
+                continue
+            release = req.json()[0]
+
+            java_version = f"{release['featureVersion']}.{release['interimVersion']}.{release['updateVersion']}"
+            full_version = f"{java_version}+{release['buildVersion']}"
+            _add_entry(all_version, *_java_entry(
+                os_name, arch, version, full_version, java_version, "librca", "BellSoft Liberica",
+                release["filename"], release["downloadUrl"], release["sha1"], release.get("LTS", False),
+                image_type, "sha1"))
 
-    with open("../registry/java_versions.json", "w") as f:
-        json.dump(all_version, f, indent=2)
+
+def _graalvm(all_version, lts_versions):
+    """
+    Fetches the latest GraalVM Community Edition build of every major version from GitHub releases
+    Docs: https://github.com/graalvm/graalvm-ce-builds/releases
+    """
+    RELEASES_API = "https://api.github.com/repos/graalvm/graalvm-ce-builds/releases"
+    GRAAL_OS = {"linux": "linux", "mac": "macos", "windows": "windows"}
+
+    req = requests.get(RELEASES_API, params={"per_page": 100})
+    if req.status_code != 200:
+        print(f"Failed to fetch GraalVM releases, status code: {req.status_code}")
+        return
+
+    seen_versions = set()
+    for release in req.json():
+        tag = release["tag_name"]
+        if release.get("prerelease") or not tag.startswith("jdk-"):
+            continue
+        java_version = tag.removeprefix("jdk-")
+        major = int(java_version.split(".")[0])
+        # keep only the latest release of every major version
+        if major in seen_versions:
+            continue
+        seen_versions.add(major)
+        print(f"Fetching details for Java {major} from GraalVM CE...")
+
+        assets = {asset["name"]: asset["browser_download_url"] for asset in release["assets"]}
+        for os_name, arch in JAVA_PLATFORMS:
+            ext = "zip" if os_name == "windows" else "tar.gz"
+            name = f"graalvm-community-jdk-{java_version}_{GRAAL_OS[os_name]}-{arch}_bin.{ext}"
+            if name not in assets or f"{name}.sha256" not in assets:
+                continue
+            checksum = requests.get(assets[f"{name}.sha256"])
+            if checksum.status_code != 200:
+                continue
+
+            _add_entry(all_version, *_java_entry(
+                os_name, arch, major, java_version, java_version, "graal", "GraalVM CE",
+                name, assets[name], checksum.text.split()[0], major in lts_versions))
 
 
 def fetch_all_java_versions():
     """
     Fetches all available Java versions from different providers and saves them to a JSON file
     """
-    _adoptium()
-    # add other providers here
+    req = requests.get("https://api.adoptium.net/v3/info/available_releases")
+    releases = req.json()
+    available_versions = releases["available_releases"]
+    lts_versions = releases["available_lts_releases"]
+
+    all_version = {}
+    _adoptium(all_version)
+    _corretto(all_version, lts_versions)
+    _zulu(all_version, available_versions, lts_versions)
+    _liberica(all_version, available_versions)
+    _graalvm(all_version, lts_versions)
+
+    with open("../registry/java_versions.json", "w") as f:
+        json.dump(all_version, f, indent=2)
 
 # ============================================ Go Versions ============================================
 # This script fetches all available Go versions from source and saves them to a JSON file
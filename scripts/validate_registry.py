@@ -86,7 +86,8 @@ def validate_java_versions():
     Validates Java versions in the registry by:
     1. Checking if versions exist in Adoptium API
     2. Verifying version format
-    3. Removing invalid entries
+    3. Checking vendor and identifier fields exist
+    4. Removing invalid entries
     """
     print("\nValidating Java versions...")
     
@@ -118,6 +119,7 @@ def validate_java_versions():
             registry_versions.add(version)
             is_valid = True
             
+            # Every vendor publishes a subset of the Adoptium feature releases
             if version not in available_versions:
                 is_valid = False
                 invalid_entries.append({
@@ -125,6 +127,13 @@ def validate_java_versions():
                     'version': version,
                     'reason': 'Not in available releases'
                 })
+            elif not entry.get('vendor') or not entry.get('identifier'):
+                is_valid = False
+                invalid_entries.append({
+                    'os': os_name,
+                    'version': version,
+                    'reason': 'Missing vendor or identifier field'
+                })
             
             if is_valid:
                 cleaned_registry[os_name].append(entry)
@@ -2,9 +2,19 @@ package tui
 
 import (
 	"fmt"
+	"os"
+
+	"github.com/charmbracelet/x/term"
 )
 
+// Plain turns off screen clearing and interactive tables, for logs and CI. It is on when stdout is not a
+// terminal, e.g. when the output is piped.
+var Plain = !term.IsTerminal(os.Stdout.Fd())
+
 // Clear only clear from pointer
 func Clear() {
+	if Plain {
+		return
+	}
 	fmt.Print("\033[H\033[2J")
 }
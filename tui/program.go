@@ -0,0 +1,12 @@
+package tui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// ProgramOptions are the options of the spinners and progress bars. They neither read the terminal nor draw in
+// plain mode, so that they run without a TTY.
+func ProgramOptions(opts ...tea.ProgramOption) []tea.ProgramOption {
+	if Plain {
+		opts = append(opts, tea.WithInput(nil), tea.WithoutRenderer())
+	}
+	return opts
+}
@@ -1,12 +1,11 @@
 package tui
 
 import (
-	"io"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -20,7 +19,14 @@ const (
 
 type ProgressMsg float64
 
-type ProgressErrMsg struct{ err error }
+// ProgressStatusMsg switches the progress bar to an indeterminate spinner with a status,
+// it is used when the size of the download is unknown
+type ProgressStatusMsg string
+
+type ProgressErrMsg struct{ Err error }
+
+// ProgressDoneMsg is sent once the download is complete
+type ProgressDoneMsg struct{}
 
 func finalPause() tea.Cmd {
 	return tea.Tick(time.Millisecond*750, func(_ time.Time) tea.Msg {
@@ -28,20 +34,24 @@ func finalPause() tea.Cmd {
 	})
 }
 
-type DownloadProgressWriter struct {
-	Total      int
-	downloaded int
-	File       *os.File
-	Reader     io.Reader
-	OnProgress func(float64)
-}
-
 type DownloadProgressModel struct {
-	Pw       *DownloadProgressWriter
 	Progress progress.Model
+	spinner  spinner.Model
+	status   string
 	err      error
 }
 
+// NewDownloadProgressModel returns a DownloadProgressModel with the default gradient
+func NewDownloadProgressModel() DownloadProgressModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	return DownloadProgressModel{
+		Progress: progress.New(progress.WithDefaultGradient()),
+		spinner:  s,
+	}
+}
+
 func (m DownloadProgressModel) Init() tea.Cmd {
 	return nil
 }
@@ -59,18 +69,31 @@ func (m DownloadProgressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case ProgressErrMsg:
-		m.err = msg.err
+		m.err = msg.Err
 		return m, tea.Quit
 
+	case ProgressDoneMsg:
+		if m.status != "" {
+			return m, tea.Quit
+		}
+		return m, tea.Batch(m.Progress.SetPercent(1.0), tea.Sequence(finalPause(), tea.Quit))
+
 	case ProgressMsg:
-		var cmds []tea.Cmd
+		return m, m.Progress.SetPercent(float64(msg))
 
-		if msg >= 1.0 {
-			cmds = append(cmds, tea.Sequence(finalPause(), tea.Quit))
+	case ProgressStatusMsg:
+		// start spinning on the first status
+		var cmd tea.Cmd
+		if m.status == "" {
+			cmd = m.spinner.Tick
 		}
+		m.status = string(msg)
+		return m, cmd
 
-		cmds = append(cmds, m.Progress.SetPercent(float64(msg)))
-		return m, tea.Batch(cmds...)
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 
 	// FrameMsg is sent when the progress bar wants to animate itself
 	case progress.FrameMsg:
@@ -89,23 +112,12 @@ func (m DownloadProgressModel) View() string {
 	}
 
 	pad := strings.Repeat(" ", padding)
+	if m.status != "" {
+		return "Downloading...\n\n" +
+			pad + m.spinner.View() + " " + m.status + "\n\n" +
+			pad + helpStyleDownload("Press any key to quit")
+	}
 	return "Downloading...\n\n" +
 		pad + m.Progress.View() + "\n\n" +
 		pad + helpStyleDownload("Press any key to quit")
 }
-
-func (pw *DownloadProgressWriter) Start(p *tea.Program) {
-	// TeeReader calls pw.Write() each time a new response is received
-	_, err := io.Copy(pw.File, io.TeeReader(pw.Reader, pw))
-	if err != nil {
-		p.Send(ProgressErrMsg{err})
-	}
-}
-
-func (pw *DownloadProgressWriter) Write(p []byte) (int, error) {
-	pw.downloaded += len(p)
-	if pw.Total > 0 && pw.OnProgress != nil {
-		pw.OnProgress(float64(pw.downloaded) / float64(pw.Total))
-	}
-	return len(p), nil
-}
@@ -3,6 +3,8 @@ package tui
 import (
 	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
@@ -43,6 +45,16 @@ func (m TableModel) View() string {
 }
 
 func Table(cols []string, rows [][]string) {
+	if Plain {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(cols, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		w.Flush()
+		return
+	}
+
 	var columns []table.Column
 	for _, col := range cols {
 		columns = append(columns, table.Column{